@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"context"
+
 	"github.com/gravestench/runtime/pkg"
 )
 
@@ -28,9 +30,22 @@ type (
 
 // use these interfaces to build your runtime services
 type (
-	HasGracefulShutdown = pkg.HasGracefulShutdown
-	HasLogger           = pkg.HasLogger
-	HasDependencies     = pkg.HasDependencies
+	HasGracefulShutdown     = pkg.HasGracefulShutdown
+	HasLogger               = pkg.HasLogger
+	HasSlogLogger           = pkg.HasSlogLogger
+	HasDependencies         = pkg.HasDependencies
+	HasDeclaredDependencies = pkg.HasDeclaredDependencies
+	HasOptionalDependencies = pkg.HasOptionalDependencies
+	HasEventReplay          = pkg.HasEventReplay
+	HasRequestHandlers      = pkg.HasRequestHandlers
+	HasMetadata             = pkg.HasMetadata
+	HasClock                = pkg.HasClock
+	HasTaskGroup            = pkg.HasTaskGroup
+
+	HasAllInitializedHook = pkg.HasAllInitializedHook
+	HasStartHook          = pkg.HasStartHook
+	HasStopHook           = pkg.HasStopHook
+	HasStoppedHook        = pkg.HasStoppedHook
 
 	EventHandlerServiceAdded                = pkg.EventHandlerServiceAdded
 	EventHandlerServiceRemoved              = pkg.EventHandlerServiceRemoved
@@ -41,7 +56,209 @@ type (
 	EventHandlerRuntimeShutdownInitiated    = pkg.EventHandlerRuntimeShutdownInitiated
 	EventHandlerDependencyResolutionStarted = pkg.EventHandlerDependencyResolutionStarted
 	EventHandlerDependencyResolutionEnded   = pkg.EventHandlerDependencyResolutionEnded
+	EventHandlerServiceReplaced             = pkg.EventHandlerServiceReplaced
+	EventHandlerRuntimeReady                = pkg.EventHandlerRuntimeReady
+	EventHandlerServiceError                = pkg.EventHandlerServiceError
+)
+
+// the runtime event bus, the records of its event history, and the
+// subscriptions with explicit delivery guarantees
+type (
+	EventBus        = pkg.EventBus
+	EventRecord     = pkg.EventRecord
+	Subscription    = pkg.Subscription
+	SubscribeOption = pkg.SubscribeOption
+	DeliveryMode    = pkg.DeliveryMode
+	OverflowPolicy  = pkg.OverflowPolicy
+)
+
+const (
+	ReplayAll = pkg.ReplayAll
+
+	DeliveryAsyncUnordered = pkg.DeliveryAsyncUnordered
+	DeliverySync           = pkg.DeliverySync
+	DeliveryAsyncOrdered   = pkg.DeliveryAsyncOrdered
+
+	OverflowBlock      = pkg.OverflowBlock
+	OverflowDropNewest = pkg.OverflowDropNewest
+	OverflowDropOldest = pkg.OverflowDropOldest
 )
 
+var NewEventBus = pkg.NewEventBus
+
+// the registry of services, and the policy for services added under a name
+// that is already taken
+type NameConflictPolicy = pkg.NameConflictPolicy
+
+const (
+	NameConflictReject  = pkg.NameConflictReject
+	NameConflictSuffix  = pkg.NameConflictSuffix
+	NameConflictReplace = pkg.NameConflictReplace
+)
+
+var (
+	ErrServiceNotFound  = pkg.ErrServiceNotFound
+	ErrServiceExists    = pkg.ErrServiceExists
+	ErrServiceNameTaken = pkg.ErrServiceNameTaken
+)
+
+// the state of services, and the graph of their dependencies
+type (
+	ServiceState    = pkg.ServiceState
+	DependencyGraph = pkg.DependencyGraph
+	DependencyNode  = pkg.DependencyNode
+	DependencyEdge  = pkg.DependencyEdge
+)
+
+const (
+	ServiceStatePending      = pkg.ServiceStatePending
+	ServiceStateResolving    = pkg.ServiceStateResolving
+	ServiceStateInitializing = pkg.ServiceStateInitializing
+	ServiceStateRunning      = pkg.ServiceStateRunning
+	ServiceStateStopped      = pkg.ServiceStateStopped
+)
+
+// the goroutines of services, see HasTaskGroup
+type (
+	TaskGroup     = pkg.TaskGroup
+	GoroutineLeak = pkg.GoroutineLeak
+)
+
+// worker pools owned by the runtime, see Runtime.WorkerPool
+type (
+	WorkerPool      = pkg.WorkerPool
+	WorkerPoolStats = pkg.WorkerPoolStats
+)
+
+// the errors reported to the runtime, and the exit codes they map to
+type ServiceError = pkg.ServiceError
+
+var (
+	ErrStartFailed     = pkg.ErrStartFailed
+	ErrShutdownTimeout = pkg.ErrShutdownTimeout
+)
+
+var ExitCode = pkg.ExitCode
+
+const (
+	ExitCodeOK              = pkg.ExitCodeOK
+	ExitCodeError           = pkg.ExitCodeError
+	ExitCodeFatal           = pkg.ExitCodeFatal
+	ExitCodeStartFailed     = pkg.ExitCodeStartFailed
+	ExitCodeShutdownTimeout = pkg.ExitCodeShutdownTimeout
+)
+
+// the readiness of the runtime, see WithStartupTimeout
+var (
+	ErrStartupTimeout = pkg.ErrStartupTimeout
+	ErrRuntimeStopped = pkg.ErrRuntimeStopped
+)
+
+var (
+	ErrPoolClosed = pkg.ErrPoolClosed
+	ErrPoolFull   = pkg.ErrPoolFull
+)
+
+// the description of a service, see HasMetadata
+type Metadata = pkg.Metadata
+
+var ServiceMetadata = pkg.ServiceMetadata
+
+// dependencies that are resolved on use, see NewLazy
+var ErrDependencyNotReady = pkg.ErrDependencyNotReady
+
+// NewLazy creates a handle to the named service, or to the first service of
+// type T if the name is empty, that is resolved when it is used.
+func NewLazy[T any](rt Runtime, name string) *pkg.Lazy[T] {
+	return pkg.NewLazy[T](rt, name)
+}
+
+// ServiceOfType returns the first service of type T.
+func ServiceOfType[T any](rt Runtime) (T, bool) {
+	return pkg.ServiceOfType[T](rt)
+}
+
+// ServicesOfType returns every service of type T.
+func ServicesOfType[T any](rt Runtime) []T {
+	return pkg.ServicesOfType[T](rt)
+}
+
+var (
+	WithDeliveryMode   = pkg.WithDeliveryMode
+	WithQueueSize      = pkg.WithQueueSize
+	WithOverflowPolicy = pkg.WithOverflowPolicy
+	WithReplay         = pkg.WithReplay
+)
+
+// request/reply messaging between services over the event bus
+type RequestHandler = pkg.RequestHandler
+
+var (
+	ErrNoRequestHandler      = pkg.ErrNoRequestHandler
+	ErrRequestHandlerExists  = pkg.ErrRequestHandlerExists
+	ErrUnexpectedRequestType = pkg.ErrUnexpectedRequestType
+)
+
+// HandleTypedRequest registers a handler for requests of type Req.
+func HandleTypedRequest[Req, Resp any](b *EventBus, handler func(context.Context, Req) (Resp, error)) error {
+	return pkg.HandleTypedRequest[Req, Resp](b, handler)
+}
+
+// TypedRequest makes a request of type Req, and yields the response as type Resp.
+func TypedRequest[Req, Resp any](ctx context.Context, b *EventBus, request Req) (Resp, error) {
+	return pkg.TypedRequest[Req, Resp](ctx, b, request)
+}
+
+// the formats of the log output
+type LogFormat = pkg.LogFormat
+
+const (
+	LogFormatConsole = pkg.LogFormatConsole
+	LogFormatJSON    = pkg.LogFormatJSON
+)
+
+// the in-memory capture of log records
+type (
+	LogCapture = pkg.LogCapture
+	LogRecord  = pkg.LogRecord
+	LogQuery   = pkg.LogQuery
+)
+
+var NewSlogHandler = pkg.NewSlogHandler
+
+// options for New
+type Option = pkg.Option
+
+var (
+	WithLogLevel       = pkg.WithLogLevel
+	WithLogDestination = pkg.WithLogDestination
+	WithLogFormat      = pkg.WithLogFormat
+	WithLogTimeFormat  = pkg.WithLogTimeFormat
+	WithLogger         = pkg.WithLogger
+
+	WithSignals            = pkg.WithSignals
+	WithoutSignalHandling  = pkg.WithoutSignalHandling
+	WithShutdownTimeout    = pkg.WithShutdownTimeout
+	WithStartupTimeout     = pkg.WithStartupTimeout
+	WithEventBus           = pkg.WithEventBus
+	WithEventForwarding    = pkg.WithEventForwarding
+	WithClock              = pkg.WithClock
+	WithRegistryHooks      = pkg.WithRegistryHooks
+	WithNameConflictPolicy = pkg.WithNameConflictPolicy
+
+	WithOptionalDependencyTimeout = pkg.WithOptionalDependencyTimeout
+	WithTaskShutdownTimeout       = pkg.WithTaskShutdownTimeout
+	WithLeakDetection             = pkg.WithLeakDetection
+)
+
+// the source of time of the runtime, and the hooks called on registration
+type (
+	Clock         = pkg.Clock
+	Ticker        = pkg.Ticker
+	RegistryHooks = pkg.RegistryHooks
+)
+
+var SystemClock = pkg.SystemClock
+
 var New = pkg.New
 var _ = New
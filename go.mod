@@ -1,6 +1,6 @@
 module github.com/gravestench/runtime
 
-go 1.19
+go 1.21
 
 require (
 	github.com/gravestench/eventemitter v0.0.0-20230922020814-8ccd81f6aaf9
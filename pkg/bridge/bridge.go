@@ -0,0 +1,551 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+const (
+	// DefaultReconnectInterval is how long a bridge waits before connecting
+	// to a peer again after the connection was lost, unless configured
+	// otherwise.
+	DefaultReconnectInterval = time.Second
+
+	// DefaultHandshakeTimeout is how long a bridge waits for a remote bridge
+	// to introduce itself, unless configured otherwise.
+	DefaultHandshakeTimeout = time.Second * 5
+
+	// DefaultWriteTimeout is how long a bridge waits for a message to be
+	// written to a remote bridge before it gives up on the connection,
+	// unless configured otherwise.
+	DefaultWriteTimeout = time.Second * 10
+)
+
+// originPrefix marks the origin of the events a bridge imports into the
+// runtime event bus, followed by the path of the event.
+const (
+	originPrefix    = "bridge:"
+	originSeparator = ","
+)
+
+var (
+	_ pkg.IsRuntimeService    = &Bridge{}
+	_ pkg.HasLogger           = &Bridge{}
+	_ pkg.HasGracefulShutdown = &Bridge{}
+)
+
+// Config describes which events a bridge forwards, and to where.
+type Config struct {
+	// Name is the name of the bridge service. Defaults to "Event Bridge".
+	Name string
+
+	// ID uniquely identifies the bridge among all connected bridges, and
+	// is used to prevent events from being forwarded in a loop. Defaults
+	// to one generated from the hostname and process ID.
+	ID string
+
+	// Listen is the transport on which connections from remote bridges
+	// are accepted. Optional.
+	Listen Transport
+
+	// Peers are the transports of the remote bridges to connect to. The
+	// bridge reconnects to them when a connection is lost.
+	Peers []Transport
+
+	// Codec encodes the messages sent over connections. Both ends of a
+	// connection must use the same codec. Defaults to JSON.
+	Codec Codec
+
+	// Export holds the names of the events forwarded to remote bridges.
+	Export []string
+
+	// Import holds the names of the events accepted from remote bridges
+	// and emitted on the local event bus.
+	Import []string
+
+	// ReconnectInterval is how long to wait before connecting to a peer
+	// again. Defaults to DefaultReconnectInterval.
+	ReconnectInterval time.Duration
+
+	// HandshakeTimeout is how long to wait for a remote bridge to introduce
+	// itself once connected. Defaults to DefaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	// WriteTimeout is how long to wait for a message to be written to a
+	// remote bridge before closing the connection. Defaults to
+	// DefaultWriteTimeout.
+	WriteTimeout time.Duration
+
+	// QueueSize is the number of exported events of each name that wait to
+	// be exported, and the number of events that wait to be sent to each
+	// peer. When the peers are too slow to keep up, the oldest waiting event
+	// is dropped, so that emitting on the local event bus never blocks, and
+	// a slow peer does not hold back the others. Defaults to
+	// pkg.DefaultEventQueueSize.
+	QueueSize int
+}
+
+// Bridge is a runtime service that forwards selected events of the runtime
+// event bus to the event buses of runtimes in other processes, and emits
+// the events it receives from them.
+type Bridge struct {
+	config Config
+	rt     pkg.IsRuntime
+	logger *zerolog.Logger
+
+	mu            sync.Mutex
+	peers         map[*peer]struct{}
+	listener      net.Listener
+	subscriptions []*pkg.Subscription
+	imports       map[string]struct{}
+	quit          chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup
+	dropped       atomic.Uint64
+}
+
+// peer is a connection to a remote bridge. The messages sent to it wait in
+// its outbox, which is written to the connection by its own goroutine.
+type peer struct {
+	id     string
+	conn   net.Conn
+	enc    Encoder
+	outbox chan *Message
+}
+
+// New creates a bridge with the given config. Add it to a runtime to start
+// forwarding events.
+func New(config Config) *Bridge {
+	if config.Name == "" {
+		config.Name = "Event Bridge"
+	}
+
+	if config.ID == "" {
+		config.ID = generateID()
+	}
+
+	if config.Codec == nil {
+		config.Codec = JSON
+	}
+
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = DefaultReconnectInterval
+	}
+
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultWriteTimeout
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = pkg.DefaultEventQueueSize
+	}
+
+	imports := make(map[string]struct{})
+	for _, event := range config.Import {
+		imports[event] = struct{}{}
+	}
+
+	return &Bridge{
+		config:  config,
+		peers:   make(map[*peer]struct{}),
+		imports: imports,
+		quit:    make(chan struct{}),
+	}
+}
+
+// Init subscribes to the exported events, starts listening for remote
+// bridges, and connects to the peers.
+func (b *Bridge) Init(rt pkg.IsRuntime) {
+	b.rt = rt
+
+	for _, event := range b.config.Export {
+		sub := rt.Events().SubscribeRecords(event, b.export,
+			pkg.WithDeliveryMode(pkg.DeliveryAsyncOrdered),
+			pkg.WithQueueSize(b.config.QueueSize),
+			pkg.WithOverflowPolicy(pkg.OverflowDropOldest),
+		)
+
+		b.subscriptions = append(b.subscriptions, sub)
+	}
+
+	if b.config.Listen != nil {
+		listener, err := b.config.Listen.Listen()
+		if err != nil {
+			b.logger.Error().Err(err).Msgf("listening on %s", b.config.Listen)
+		} else {
+			b.logger.Info().Msgf("listening on %s", b.config.Listen)
+			b.listener = listener
+			b.wg.Add(1)
+			go b.accept(listener)
+		}
+	}
+
+	for _, transport := range b.config.Peers {
+		b.wg.Add(1)
+		go b.dial(transport)
+	}
+}
+
+// Name returns the name of the bridge service.
+func (b *Bridge) Name() string {
+	return b.config.Name
+}
+
+// ID returns the ID of the bridge.
+func (b *Bridge) ID() string {
+	return b.config.ID
+}
+
+// BindLogger sets the logger of the bridge.
+func (b *Bridge) BindLogger(logger *zerolog.Logger) {
+	b.logger = logger
+}
+
+// Logger yields the logger of the bridge.
+func (b *Bridge) Logger() *zerolog.Logger {
+	return b.logger
+}
+
+// OnShutdown stops forwarding events, and closes every connection. It may be
+// called more than once.
+func (b *Bridge) OnShutdown() {
+	b.stopOnce.Do(b.stop)
+}
+
+func (b *Bridge) stop() {
+	close(b.quit)
+
+	for _, sub := range b.subscriptions {
+		sub.Unsubscribe()
+	}
+
+	b.mu.Lock()
+
+	if b.listener != nil {
+		_ = b.listener.Close()
+	}
+
+	for p := range b.peers {
+		_ = p.conn.Close()
+	}
+
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// Dropped returns the number of exported events that were dropped because
+// the peers did not keep up, see Config.QueueSize.
+func (b *Bridge) Dropped() uint64 {
+	dropped := b.dropped.Load()
+
+	for _, sub := range b.subscriptions {
+		dropped += sub.Dropped()
+	}
+
+	return dropped
+}
+
+// Peers returns the IDs of the connected remote bridges.
+func (b *Bridge) Peers() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids := make([]string, 0, len(b.peers))
+	for p := range b.peers {
+		if p.id != "" {
+			ids = append(ids, p.id)
+		}
+	}
+
+	return ids
+}
+
+func (b *Bridge) accept(listener net.Listener) {
+	defer b.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !b.stopping() {
+				b.logger.Error().Err(err).Msg("accepting connection")
+			}
+
+			return
+		}
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.serve(conn)
+		}()
+	}
+}
+
+func (b *Bridge) dial(transport Transport) {
+	defer b.wg.Done()
+
+	for !b.stopping() {
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.ReconnectInterval*5)
+		conn, err := transport.Dial(ctx)
+		cancel()
+
+		if err != nil {
+			b.logger.Debug().Err(err).Msgf("connecting to %s", transport)
+		} else {
+			b.serve(conn)
+		}
+
+		select {
+		case <-b.quit:
+			return
+		case <-time.After(b.config.ReconnectInterval):
+		}
+	}
+}
+
+// serve exchanges the IDs of both bridges, then imports the events received
+// over the connection until it is closed.
+func (b *Bridge) serve(conn net.Conn) {
+	defer conn.Close()
+
+	p := &peer{
+		conn:   conn,
+		enc:    b.config.Codec.NewEncoder(conn),
+		outbox: make(chan *Message, b.config.QueueSize),
+	}
+
+	dec := b.config.Codec.NewDecoder(conn)
+
+	// the peer is tracked before the handshake, so that shutting down
+	// closes connections that are stuck in it
+	if !b.addPeer(p) {
+		return
+	}
+
+	defer b.removePeer(p)
+
+	if err := p.send(&Message{Hello: b.config.ID}, b.config.WriteTimeout); err != nil {
+		b.logger.Error().Err(err).Msg("sending hello")
+		return
+	}
+
+	var hello Message
+
+	_ = conn.SetReadDeadline(time.Now().Add(b.config.HandshakeTimeout))
+
+	if err := dec.Decode(&hello); err != nil || hello.Hello == "" {
+		b.logger.Error().Err(err).Msg("expected hello from remote bridge")
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if hello.Hello == b.config.ID {
+		b.logger.Warn().Msg("connected to itself, closing connection")
+		return
+	}
+
+	b.mu.Lock()
+	p.id = hello.Hello
+	b.mu.Unlock()
+
+	b.logger.Info().Msgf("connected to bridge %q", p.id)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+		b.write(p, done)
+	}()
+
+	for {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			if !b.stopping() && !errors.Is(err, net.ErrClosed) {
+				b.logger.Warn().Err(err).Msgf("connection to bridge %q lost", p.id)
+			}
+
+			return
+		}
+
+		b.importEvent(&msg)
+	}
+}
+
+func (b *Bridge) addPeer(p *peer) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopping() {
+		return false
+	}
+
+	b.peers[p] = struct{}{}
+
+	return true
+}
+
+func (b *Bridge) removePeer(p *peer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.peers, p)
+}
+
+// export forwards a local event to every connected bridge it has not
+// already passed through.
+func (b *Bridge) export(record pkg.EventRecord) {
+	path := parseOrigin(record.Origin)
+	if contains(path, b.config.ID) {
+		return // the event has already been forwarded by this bridge
+	}
+
+	msg := &Message{
+		Event: record.Name,
+		Args:  record.Args,
+		Path:  append(path, b.config.ID),
+	}
+
+	b.mu.Lock()
+	peers := make([]*peer, 0, len(b.peers))
+	for p := range b.peers {
+		// skip connections that have not completed the handshake, and
+		// bridges the event has already passed through
+		if p.id != "" && !contains(msg.Path, p.id) {
+			peers = append(peers, p)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, p := range peers {
+		if !p.enqueue(msg) {
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// write sends the messages of the outbox of the peer until done is closed. A
+// message that cannot be written in time closes the connection, which is
+// then reconnected.
+func (b *Bridge) write(p *peer, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-p.outbox:
+			if err := p.send(msg, b.config.WriteTimeout); err != nil {
+				if !b.stopping() && !errors.Is(err, net.ErrClosed) {
+					b.logger.Warn().Err(err).Msgf("forwarding event %q to bridge %q", msg.Event, p.id)
+				}
+
+				_ = p.conn.Close()
+
+				return
+			}
+		}
+	}
+}
+
+// importEvent emits an event received from a remote bridge on the local
+// event bus, if it is imported.
+func (b *Bridge) importEvent(msg *Message) {
+	if _, imported := b.imports[msg.Event]; !imported {
+		b.logger.Debug().Msgf("ignoring event %q, it is not imported", msg.Event)
+		return
+	}
+
+	if contains(msg.Path, b.config.ID) {
+		return // the event has looped back to this bridge
+	}
+
+	b.rt.Events().EmitFrom(formatOrigin(msg.Path), msg.Event, msg.Args...)
+}
+
+func (b *Bridge) stopping() bool {
+	select {
+	case <-b.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueue adds a message to the outbox of the peer, dropping the oldest
+// waiting message if it is full. It returns false if a message was dropped.
+func (p *peer) enqueue(msg *Message) bool {
+	kept := true
+
+	for {
+		select {
+		case p.outbox <- msg:
+			return kept
+		default:
+		}
+
+		select {
+		case <-p.outbox:
+			kept = false
+		default:
+		}
+	}
+}
+
+// send writes a message to the connection, failing if it takes longer than
+// the timeout.
+func (p *peer) send(msg *Message, timeout time.Duration) error {
+	_ = p.conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	return p.enc.Encode(msg)
+}
+
+func formatOrigin(path []string) string {
+	return originPrefix + strings.Join(path, originSeparator)
+}
+
+func parseOrigin(origin string) []string {
+	if !strings.HasPrefix(origin, originPrefix) {
+		return nil
+	}
+
+	return strings.Split(strings.TrimPrefix(origin, originPrefix), originSeparator)
+}
+
+func contains(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func generateID() string {
+	hostname, _ := os.Hostname()
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,201 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+func TestBridge(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSON, "binary": Binary} {
+		t.Run(name, func(t *testing.T) {
+			socket := Unix(filepath.Join(t.TempDir(), "bridge.sock"))
+
+			rtA, rtB := pkg.New("A"), pkg.New("B")
+
+			bridgeA := New(Config{ID: "a", Listen: socket, Codec: codec, Export: []string{"ping"}, Import: []string{"pong"}})
+			bridgeB := New(Config{ID: "b", Peers: []Transport{socket}, Codec: codec, Export: []string{"pong"}, Import: []string{"ping"}, ReconnectInterval: time.Millisecond * 10})
+
+			rtA.Add(bridgeA).Wait()
+			rtB.Add(bridgeB).Wait()
+
+			defer bridgeA.OnShutdown()
+			defer bridgeB.OnShutdown()
+
+			// answer every ping received from A with a pong
+			rtB.Events().On("ping", func(args ...any) {
+				rtB.Events().Emit("pong", args...)
+			})
+
+			pongs := make(chan string, 1)
+			rtA.Events().On("pong", func(args ...any) {
+				pongs <- args[0].(string)
+			})
+
+			for len(bridgeA.Peers()) == 0 || len(bridgeB.Peers()) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			rtA.Events().Emit("ping", "hello")
+
+			select {
+			case got := <-pongs:
+				if got != "hello" {
+					t.Errorf("unexpected pong %q", got)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatal("timed out waiting for pong")
+			}
+
+			for _, record := range rtA.Events().History() {
+				if record.Name == "ping" && record.Origin != "" {
+					t.Errorf("ping was forwarded back to its origin: %+v", record)
+				}
+			}
+		})
+	}
+}
+
+func TestBridge_SlowPeer(t *testing.T) {
+	socket := Unix(filepath.Join(t.TempDir(), "bridge.sock"))
+
+	rt := pkg.New("A", pkg.WithoutSignalHandling())
+
+	b := New(Config{ID: "a", Listen: socket, Export: []string{"ping"}, QueueSize: 4})
+	rt.Add(b).Wait()
+
+	// a peer that completes the handshake, then never reads
+	conn, err := socket.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if err = JSON.NewEncoder(conn).Encode(&Message{Hello: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for len(b.Peers()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// emitting does not block on the peer, the events it cannot take are
+	// dropped instead
+	emitted := make(chan struct{})
+
+	go func() {
+		payload := strings.Repeat("x", 64*1024)
+
+		for i := 0; i < 256; i++ {
+			rt.Events().Emit("ping", payload)
+		}
+
+		close(emitted)
+	}()
+
+	select {
+	case <-emitted:
+	case <-time.After(time.Second * 5):
+		t.Fatal("emitting blocked on a slow peer")
+	}
+
+	if b.Dropped() == 0 {
+		t.Error("expected events to be dropped")
+	}
+
+	b.OnShutdown()
+	b.OnShutdown() // shutting down again is harmless
+}
+
+func TestBridge_StalledPeer(t *testing.T) {
+	socket := Unix(filepath.Join(t.TempDir(), "bridge.sock"))
+
+	rtA := pkg.New("A", pkg.WithoutSignalHandling())
+	rtB := pkg.New("B", pkg.WithoutSignalHandling())
+
+	bridgeA := New(Config{ID: "a", Listen: socket, Export: []string{"ping"}, QueueSize: 128})
+	bridgeB := New(Config{ID: "b", Peers: []Transport{socket}, Import: []string{"ping"}, ReconnectInterval: time.Millisecond * 10})
+
+	rtA.Add(bridgeA).Wait()
+
+	defer bridgeA.OnShutdown()
+
+	// a peer that completes the handshake, then never reads
+	conn, err := socket.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if err = JSON.NewEncoder(conn).Encode(&Message{Hello: "stalled"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rtB.Add(bridgeB).Wait()
+
+	defer bridgeB.OnShutdown()
+
+	for len(bridgeA.Peers()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	received := make(chan struct{}, 64)
+	rtB.Events().On("ping", func(...any) {
+		received <- struct{}{}
+	})
+
+	// more than the stalled connection can buffer
+	payload := strings.Repeat("x", 64*1024)
+
+	for i := 0; i < 64; i++ {
+		rtA.Events().Emit("ping", payload)
+	}
+
+	for i := 0; i < 64; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("the healthy peer received %d of 64 events", i)
+		}
+	}
+}
+
+func TestBridge_HandshakeTimeout(t *testing.T) {
+	socket := Unix(filepath.Join(t.TempDir(), "bridge.sock"))
+
+	rt := pkg.New("A", pkg.WithoutSignalHandling())
+
+	b := New(Config{ID: "a", Listen: socket, HandshakeTimeout: time.Millisecond * 50})
+	rt.Add(b).Wait()
+
+	defer b.OnShutdown()
+
+	// a peer that connects, then never introduces itself
+	conn, err := socket.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	dec := JSON.NewDecoder(conn)
+
+	var hello Message
+	if err = dec.Decode(&hello); err != nil || hello.Hello != "a" {
+		t.Fatalf("expected hello, got %+v: %v", hello, err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	if err = dec.Decode(&hello); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the bridge to close the connection, got %v", err)
+	}
+}
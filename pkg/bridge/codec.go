@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MaxFrameSize is the largest message the Binary codec accepts.
+const MaxFrameSize = 16 << 20
+
+// Message is what bridges exchange over a connection.
+type Message struct {
+	// Hello is set on the first message sent over a connection, and holds
+	// the ID of the bridge that sent it.
+	Hello string `json:"hello,omitempty"`
+
+	// Event is the name of the forwarded event.
+	Event string `json:"event,omitempty"`
+
+	// Args are the arguments the event was emitted with.
+	Args []any `json:"args,omitempty"`
+
+	// Path holds the IDs of the bridges the event has passed through,
+	// starting with the bridge of the runtime that emitted it.
+	Path []string `json:"path,omitempty"`
+}
+
+// Codec encodes messages onto a connection, and decodes them from it.
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes messages to a connection.
+type Encoder interface {
+	Encode(msg *Message) error
+}
+
+// Decoder reads messages from a connection.
+type Decoder interface {
+	Decode(msg *Message) error
+}
+
+var (
+	// JSON encodes every message as a line of JSON. Event arguments are
+	// decoded as the types encoding/json yields for an `any`, eg numbers
+	// become float64.
+	JSON Codec = jsonCodec{}
+
+	// Binary encodes every message with encoding/gob, in frames prefixed
+	// with their length as a big-endian uint32. Argument types other than
+	// the basic types must be registered with gob.Register on both ends.
+	Binary Codec = binaryCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return &jsonEncoder{json.NewEncoder(w)}
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return &jsonDecoder{json.NewDecoder(r)}
+}
+
+type jsonEncoder struct {
+	*json.Encoder
+}
+
+func (e *jsonEncoder) Encode(msg *Message) error {
+	return e.Encoder.Encode(msg)
+}
+
+type jsonDecoder struct {
+	*json.Decoder
+}
+
+func (d *jsonDecoder) Decode(msg *Message) error {
+	return d.Decoder.Decode(msg)
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) NewEncoder(w io.Writer) Encoder {
+	return &binaryEncoder{w: w}
+}
+
+func (binaryCodec) NewDecoder(r io.Reader) Decoder {
+	return &binaryDecoder{r: bufio.NewReader(r)}
+}
+
+type binaryEncoder struct {
+	w io.Writer
+}
+
+func (e *binaryEncoder) Encode(msg *Message) error {
+	var payload bytes.Buffer
+
+	// every frame carries its own type information, so that frames can be
+	// decoded independently of each other
+	if err := gob.NewEncoder(&payload).Encode(msg); err != nil {
+		return err
+	}
+
+	if payload.Len() > MaxFrameSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum frame size", payload.Len())
+	}
+
+	frame := make([]byte, 4, 4+payload.Len())
+	binary.BigEndian.PutUint32(frame, uint32(payload.Len()))
+	frame = append(frame, payload.Bytes()...)
+
+	_, err := e.w.Write(frame)
+
+	return err
+}
+
+type binaryDecoder struct {
+	r io.Reader
+}
+
+func (d *binaryDecoder) Decode(msg *Message) error {
+	var header [4]byte
+
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the maximum frame size", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(msg)
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Transport is how a bridge reaches other bridges. A transport is used both
+// to accept connections from remote bridges and to connect to them.
+type Transport interface {
+	// Listen starts accepting connections from remote bridges.
+	Listen() (net.Listener, error)
+
+	// Dial connects to a remote bridge.
+	Dial(ctx context.Context) (net.Conn, error)
+
+	// String describes the transport in log messages.
+	String() string
+}
+
+// TCP yields a transport for the given TCP address, eg "127.0.0.1:7070".
+func TCP(address string) Transport {
+	return &netTransport{network: "tcp", address: address}
+}
+
+// Unix yields a transport for the Unix domain socket at the given path.
+func Unix(path string) Transport {
+	return &netTransport{network: "unix", address: path}
+}
+
+type netTransport struct {
+	network string
+	address string
+}
+
+func (t *netTransport) Listen() (net.Listener, error) {
+	return net.Listen(t.network, t.address)
+}
+
+func (t *netTransport) Dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, t.network, t.address)
+}
+
+func (t *netTransport) String() string {
+	return fmt.Sprintf("%s://%s", t.network, t.address)
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"time"
+)
+
+// Clock is the source of time used by the runtime, so that it can be
+// replaced in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Sleep pauses the current goroutine for the given duration.
+	Sleep(d time.Duration)
+
+	// After waits for the duration to elapse and then sends the current
+	// time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTicker returns a ticker that sends the current time on its channel
+	// after each period.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a Clock at intervals.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Reset stops the ticker and resets its period to the given duration.
+	Reset(d time.Duration)
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// HasClock is an optional interface for services that measure time. The
+// runtime binds its clock to them when they are added, so that their timing
+// follows a fake clock in tests.
+type HasClock interface {
+	IsRuntimeService
+
+	// BindClock sets the clock of the service.
+	BindClock(clock Clock)
+}
+
+// SystemClock is the Clock backed by the time package. It is the default
+// clock of a runtime.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Clock yields the clock of the runtime.
+func (r *Runtime) Clock() Clock {
+	return r.clock
+}
+
+// bindClock binds the clock of the runtime to the service, if it measures
+// time.
+func (r *Runtime) bindClock(service IsRuntimeService) {
+	if candidate, ok := service.(HasClock); ok && service != r {
+		candidate.BindClock(r.clock)
+	}
+}
@@ -0,0 +1,302 @@
+package pkg
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	ee "github.com/gravestench/eventemitter"
+)
+
+// DefaultEventHistorySize is the number of events retained by the event bus
+// of a runtime, unless changed with EventBus.SetHistorySize.
+const DefaultEventHistorySize = 256
+
+// ReplayAll can be given to EventBus.OnWithReplay to request that the entire
+// retained history is replayed. Sequence numbers start at 1.
+const ReplayAll uint64 = 0
+
+// EventRecord is a single entry in the event history of an EventBus.
+type EventRecord struct {
+	// Sequence is a monotonically increasing number assigned to every
+	// event emitted through the bus, starting at 1.
+	Sequence uint64
+
+	// Time is when the event was emitted.
+	Time time.Time
+
+	// Name is the name of the event.
+	Name string
+
+	// Args are the arguments the event was emitted with.
+	Args []any
+
+	// Origin identifies where the event was emitted from, as given to
+	// EventBus.EmitFrom. It is empty for events emitted locally.
+	Origin string
+}
+
+// EventBus is the event bus of the runtime. It wraps an event emitter and
+// keeps a bounded history of the events emitted through it, so that late
+// subscribers can have past events replayed to them.
+type EventBus struct {
+	*ee.EventEmitter
+
+	mu       sync.Mutex
+	sequence uint64
+	history  []EventRecord // ring buffer, oldest record at head
+	head     int
+	size     int
+
+	subscriptions map[string][]*Subscription
+	delivery      map[string][]SubscribeOption
+	dropped       map[string]uint64 // drops of removed subscriptions
+
+	requestHandlers map[string]RequestHandler
+
+	forwards []*eventForward
+
+	clock Clock
+}
+
+// eventForward re-emits the events matching its patterns on another bus.
+type eventForward struct {
+	to       *EventBus
+	origin   string
+	patterns []string
+}
+
+func (f *eventForward) matches(event string) bool {
+	for _, pattern := range f.patterns {
+		if matched, err := path.Match(pattern, event); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewEventBus creates an event bus that retains up to historySize events.
+// A historySize of zero disables the history.
+func NewEventBus(historySize int) *EventBus {
+	return &EventBus{
+		EventEmitter: ee.New(),
+		history:      make([]EventRecord, historySize),
+	}
+}
+
+// Emit records the event in the history and emits it to all registered
+// listeners. Synchronous subscriptions are invoked before Emit returns; the
+// returned wait group is done once every other listener has handled the
+// event, or the event was dropped.
+func (b *EventBus) Emit(event string, args ...any) *sync.WaitGroup {
+	return b.EmitFrom("", event, args...)
+}
+
+// EmitFrom emits an event like Emit, and records the given origin with it.
+// It is used to re-emit events that originate elsewhere, such as in another
+// process, so that subscribers of records can tell them apart.
+func (b *EventBus) EmitFrom(origin string, event string, args ...any) *sync.WaitGroup {
+	return b.emit(origin, nil, event, args...)
+}
+
+// emit emits an event that was forwarded through the given buses, so that it
+// is never forwarded back to one of them.
+func (b *EventBus) emit(origin string, via []*EventBus, event string, args ...any) *sync.WaitGroup {
+	b.mu.Lock()
+
+	record := b.record(origin, event, args)
+
+	subs := append([]*Subscription{}, b.subscriptions[event]...)
+	tickets := reserve(subs)
+	forwards := append([]*eventForward{}, b.forwards...)
+	emitted := b.EventEmitter.Emit(event, args...)
+
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		emitted.Wait()
+		wg.Done()
+	}()
+
+	b.deliver(subs, tickets, record, &wg)
+
+	via = append(via[:len(via):len(via)], b)
+
+	for _, forward := range forwards {
+		if !forward.matches(event) || forwardedVia(via, forward.to) {
+			continue
+		}
+
+		forwarded := forward.to.emit(forward.origin, via, event, args...)
+
+		wg.Add(1)
+		go func() {
+			forwarded.Wait()
+			wg.Done()
+		}()
+	}
+
+	return &wg
+}
+
+// Forward re-emits the events whose name matches one of the patterns, as
+// understood by path.Match, on another bus, with the given origin. An event
+// is never forwarded to a bus it was already emitted on, so that buses can
+// forward to each other without looping. The returned function stops
+// forwarding.
+func (b *EventBus) Forward(to *EventBus, origin string, patterns ...string) (stop func()) {
+	if to == nil || to == b || len(patterns) == 0 {
+		return func() {}
+	}
+
+	forward := &eventForward{to: to, origin: origin, patterns: patterns}
+
+	b.mu.Lock()
+	b.forwards = append(b.forwards, forward)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, candidate := range b.forwards {
+			if candidate == forward {
+				b.forwards = append(b.forwards[:i:i], b.forwards[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func forwardedVia(via []*EventBus, bus *EventBus) bool {
+	for _, candidate := range via {
+		if candidate == bus {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OnWithReplay registers a listener for a specific event, and replays every
+// retained event of that name with a sequence number greater than since.
+// Use ReplayAll to replay the entire retained history. The replayed events
+// are delivered in order, in a separate goroutine; the returned wait group
+// is done once the replay has completed.
+func (b *EventBus) OnWithReplay(event string, fn func(...any), since uint64) *sync.WaitGroup {
+	return &b.Subscribe(event, fn, WithReplay(since)).replayed
+}
+
+// History returns a copy of the retained events, oldest first.
+func (b *EventBus) History() []EventRecord {
+	return b.HistorySince(ReplayAll)
+}
+
+// HistorySince returns a copy of the retained events with a sequence number
+// greater than the given one, oldest first.
+func (b *EventBus) HistorySince(sequence uint64) []EventRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.historySince(sequence)
+}
+
+// Sequence returns the sequence number of the most recently emitted event.
+func (b *EventBus) Sequence() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sequence
+}
+
+// SetClock sets the clock that timestamps the history and times out
+// requests. Defaults to SystemClock; a runtime sets it to its own clock.
+func (b *EventBus) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clock = clock
+}
+
+// timeSource yields the clock of the bus. The caller must hold the lock.
+func (b *EventBus) timeSource() Clock {
+	if b.clock == nil {
+		return SystemClock
+	}
+
+	return b.clock
+}
+
+// SetHistorySize changes the number of retained events. When shrinking the
+// history, the oldest events are discarded.
+func (b *EventBus) SetHistorySize(historySize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if historySize < 0 {
+		historySize = 0
+	}
+
+	retained := b.historySince(ReplayAll)
+	if len(retained) > historySize {
+		retained = retained[len(retained)-historySize:]
+	}
+
+	b.history = make([]EventRecord, historySize)
+	b.head = 0
+	b.size = 0
+
+	for _, record := range retained {
+		b.push(record)
+	}
+}
+
+func (b *EventBus) record(origin, event string, args []any) EventRecord {
+	b.sequence++
+
+	record := EventRecord{
+		Sequence: b.sequence,
+		Time:     b.timeSource().Now(),
+		Name:     event,
+		Args:     append([]any{}, args...),
+		Origin:   origin,
+	}
+
+	b.push(record)
+
+	return record
+}
+
+func (b *EventBus) push(record EventRecord) {
+	capacity := len(b.history)
+	if capacity == 0 {
+		return
+	}
+
+	if b.size < capacity {
+		b.history[(b.head+b.size)%capacity] = record
+		b.size++
+		return
+	}
+
+	// the buffer is full, overwrite the oldest record
+	b.history[b.head] = record
+	b.head = (b.head + 1) % capacity
+}
+
+func (b *EventBus) historySince(sequence uint64) []EventRecord {
+	records := make([]EventRecord, 0, b.size)
+
+	for i := 0; i < b.size; i++ {
+		record := b.history[(b.head+i)%len(b.history)]
+		if record.Sequence > sequence {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
@@ -0,0 +1,489 @@
+package pkg
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// DeliveryMode describes how the events of a subscription are delivered to
+// its handler.
+type DeliveryMode int
+
+const (
+	// DeliveryAsyncUnordered invokes the handler in a new goroutine for
+	// every event. Handlers may run concurrently and in any order. This is
+	// the default, and the behavior of EventBus.On.
+	DeliveryAsyncUnordered DeliveryMode = iota
+
+	// DeliverySync invokes the handler in the goroutine that emits the
+	// event, before Emit returns. Synchronous handlers of an event are
+	// invoked in the order they were subscribed.
+	DeliverySync
+
+	// DeliveryAsyncOrdered queues the events for the subscriber and invokes
+	// the handler from a single goroutine, preserving the order in which
+	// the events were emitted.
+	DeliveryAsyncOrdered
+)
+
+// OverflowPolicy describes what happens when an event is emitted while the
+// queue of a DeliveryAsyncOrdered subscription is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Emit block until there is room in the queue,
+	// applying backpressure to the emitter.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the event being emitted.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest queued event to make room for
+	// the event being emitted.
+	OverflowDropOldest
+)
+
+// DefaultEventQueueSize is the queue size of DeliveryAsyncOrdered
+// subscriptions, unless changed with WithQueueSize.
+const DefaultEventQueueSize = 64
+
+// SubscribeOption configures a subscription made with EventBus.Subscribe.
+type SubscribeOption func(*subscriptionConfig)
+
+type subscriptionConfig struct {
+	mode      DeliveryMode
+	queueSize int
+	overflow  OverflowPolicy
+	replay    bool
+	since     uint64
+}
+
+// WithDeliveryMode sets the delivery mode of the subscription.
+func WithDeliveryMode(mode DeliveryMode) SubscribeOption {
+	return func(c *subscriptionConfig) {
+		c.mode = mode
+	}
+}
+
+// WithQueueSize sets the queue size of a DeliveryAsyncOrdered subscription.
+func WithQueueSize(size int) SubscribeOption {
+	return func(c *subscriptionConfig) {
+		if size < 1 {
+			size = 1
+		}
+
+		c.queueSize = size
+	}
+}
+
+// WithOverflowPolicy sets what happens when the queue of a
+// DeliveryAsyncOrdered subscription is full.
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(c *subscriptionConfig) {
+		c.overflow = policy
+	}
+}
+
+// WithReplay replays the retained events with a sequence number greater
+// than since to the subscription, in order. With DeliveryAsyncOrdered, they
+// are delivered before any live event. Use ReplayAll to replay the entire
+// retained history.
+func WithReplay(since uint64) SubscribeOption {
+	return func(c *subscriptionConfig) {
+		c.replay = true
+		c.since = since
+	}
+}
+
+// Subscription is a handler subscribed to an event of an EventBus with
+// explicit delivery guarantees.
+type Subscription struct {
+	bus           *EventBus
+	event         string
+	handler       func(...any)
+	recordHandler func(EventRecord)
+	config        subscriptionConfig
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []queuedEvent
+	closed bool
+
+	// tickets is the number of places reserved in the queue, and turn the
+	// place of the next event to be queued
+	tickets uint64
+	turn    uint64
+
+	replayed  sync.WaitGroup
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+type queuedEvent struct {
+	record EventRecord
+	done   func()
+}
+
+// Event returns the name of the event the subscription is for.
+func (s *Subscription) Event() string {
+	return s.event
+}
+
+// Mode returns the delivery mode of the subscription.
+func (s *Subscription) Mode() DeliveryMode {
+	return s.config.mode
+}
+
+// Delivered returns the number of events delivered to the handler.
+func (s *Subscription) Delivered() uint64 {
+	return s.delivered.Load()
+}
+
+// Dropped returns the number of events discarded because the queue of the
+// subscription was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Queued returns the number of events waiting to be delivered.
+func (s *Subscription) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queue)
+}
+
+// Unsubscribe removes the subscription from the bus. Events that are
+// already queued are discarded.
+func (s *Subscription) Unsubscribe() {
+	s.bus.unsubscribe(s)
+	s.close()
+}
+
+// SetDelivery sets the default options of subscriptions to the given event.
+// They apply to EventBus.On and EventBus.Subscribe, and the options given
+// to Subscribe take precedence over them. Subscriptions that already exist
+// are not changed.
+func (b *EventBus) SetDelivery(event string, options ...SubscribeOption) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.delivery == nil {
+		b.delivery = make(map[string][]SubscribeOption)
+	}
+
+	b.delivery[event] = options
+}
+
+// Subscribe registers a handler for a specific event with the given delivery
+// options, and returns the subscription.
+func (b *EventBus) Subscribe(event string, fn func(...any), options ...SubscribeOption) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.subscribe(event, fn, nil, options...)
+}
+
+// SubscribeRecords registers a handler for a specific event that receives
+// the complete record of each event, including its sequence number and
+// origin, instead of only its arguments.
+func (b *EventBus) SubscribeRecords(event string, fn func(EventRecord), options ...SubscribeOption) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.subscribe(event, nil, fn, options...)
+}
+
+// On registers a listener for a specific event. If a delivery has been set
+// for the event with SetDelivery, the listener is subscribed with it.
+func (b *EventBus) On(event string, fn func(...any)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, found := b.delivery[event]; found {
+		b.subscribe(event, fn, nil)
+		return
+	}
+
+	b.EventEmitter.On(event, fn)
+}
+
+// Off removes a specific listener for a specific event.
+func (b *EventBus) Off(event string, fn func(...any)) {
+	b.mu.Lock()
+
+	var found *Subscription
+
+	for _, sub := range b.subscriptions[event] {
+		if pointerOf(sub.handler) == pointerOf(fn) {
+			found = sub
+			break
+		}
+	}
+
+	b.mu.Unlock()
+
+	if found != nil {
+		found.Unsubscribe()
+		return
+	}
+
+	b.EventEmitter.Off(event, fn)
+}
+
+// Dropped returns the number of events discarded by the subscriptions of
+// the bus, by event name.
+func (b *EventBus) Dropped() map[string]uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := make(map[string]uint64)
+
+	for event, count := range b.dropped {
+		dropped[event] = count
+	}
+
+	for event, subs := range b.subscriptions {
+		for _, sub := range subs {
+			if count := sub.Dropped(); count > 0 {
+				dropped[event] += count
+			}
+		}
+	}
+
+	return dropped
+}
+
+// subscribe must be called while holding the lock of the bus.
+func (b *EventBus) subscribe(event string, fn func(...any), recordFn func(EventRecord), options ...SubscribeOption) *Subscription {
+	config := subscriptionConfig{
+		mode:      DeliveryAsyncUnordered,
+		queueSize: DefaultEventQueueSize,
+		overflow:  OverflowBlock,
+	}
+
+	for _, option := range append(append([]SubscribeOption{}, b.delivery[event]...), options...) {
+		option(&config)
+	}
+
+	sub := &Subscription{
+		bus:           b,
+		event:         event,
+		handler:       fn,
+		recordHandler: recordFn,
+		config:        config,
+	}
+
+	sub.cond = sync.NewCond(&sub.mu)
+
+	if b.subscriptions == nil {
+		b.subscriptions = make(map[string][]*Subscription)
+	}
+
+	b.subscriptions[event] = append(b.subscriptions[event], sub)
+
+	var replay []EventRecord
+
+	if config.replay {
+		for _, record := range b.historySince(config.since) {
+			if record.Name == event {
+				replay = append(replay, record)
+			}
+		}
+	}
+
+	switch config.mode {
+	case DeliveryAsyncOrdered:
+		// replayed events are queued ahead of any live event, regardless
+		// of the queue size
+		sub.replayed.Add(len(replay))
+		for _, record := range replay {
+			sub.queue = append(sub.queue, queuedEvent{record: record, done: sub.replayed.Done})
+		}
+
+		go sub.run()
+	default:
+		if len(replay) > 0 {
+			sub.replayed.Add(1)
+			go func() {
+				for _, record := range replay {
+					sub.invoke(record)
+				}
+				sub.replayed.Done()
+			}()
+		}
+	}
+
+	return sub
+}
+
+func (b *EventBus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscriptions[sub.event]
+	for idx := range subs {
+		if subs[idx] == sub {
+			b.subscriptions[sub.event] = append(subs[:idx], subs[idx+1:]...)
+			break
+		}
+	}
+
+	// keep the drop count of the subscription in the metrics of the bus
+	if count := sub.Dropped(); count > 0 {
+		if b.dropped == nil {
+			b.dropped = make(map[string]uint64)
+		}
+
+		b.dropped[sub.event] += count
+	}
+}
+
+// reserve takes a place in the queue of each DeliveryAsyncOrdered
+// subscription, and returns the places by index of the subscriptions. It must
+// be called while holding the lock of the bus, so that the places follow the
+// sequence of the events, even though they are queued after the lock is
+// released.
+func reserve(subs []*Subscription) []uint64 {
+	tickets := make([]uint64, len(subs))
+
+	for i, sub := range subs {
+		if sub.config.mode != DeliveryAsyncOrdered {
+			continue
+		}
+
+		sub.mu.Lock()
+		tickets[i] = sub.tickets
+		sub.tickets++
+		sub.mu.Unlock()
+	}
+
+	return tickets
+}
+
+// deliver dispatches an event to the given subscriptions, at the places
+// reserved in the queues of the ordered ones. It must be called without
+// holding the lock of the bus, as synchronous handlers may emit.
+func (b *EventBus) deliver(subs []*Subscription, tickets []uint64, record EventRecord, wg *sync.WaitGroup) {
+	// the ordered subscriptions are served first, so that the events emitted
+	// by a synchronous handler never wait for the place of this one
+	for i, sub := range subs {
+		if sub.config.mode == DeliveryAsyncOrdered {
+			wg.Add(1)
+			sub.enqueue(queuedEvent{record: record, done: wg.Done}, tickets[i])
+		}
+	}
+
+	for _, sub := range subs {
+		switch sub.config.mode {
+		case DeliverySync:
+			sub.invoke(record)
+		case DeliveryAsyncOrdered:
+		default:
+			wg.Add(1)
+			go func(sub *Subscription) {
+				sub.invoke(record)
+				wg.Done()
+			}(sub)
+		}
+	}
+}
+
+func (s *Subscription) invoke(record EventRecord) {
+	if s.recordHandler != nil {
+		s.recordHandler(record)
+	} else {
+		s.handler(record.Args...)
+	}
+
+	s.delivered.Add(1)
+}
+
+// enqueue queues an event at the given place, once the events at the places
+// before it are queued or dropped.
+func (s *Subscription) enqueue(event queuedEvent, ticket uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for !s.closed && s.turn != ticket {
+		s.cond.Wait()
+	}
+
+	defer func() {
+		s.turn++
+		s.cond.Broadcast()
+	}()
+
+	for !s.closed && len(s.queue) >= s.config.queueSize {
+		switch s.config.overflow {
+		case OverflowDropNewest:
+			s.dropped.Add(1)
+			event.done()
+			return
+		case OverflowDropOldest:
+			oldest := s.queue[0]
+			s.queue = s.queue[1:]
+			s.dropped.Add(1)
+			oldest.done()
+		default:
+			s.cond.Wait()
+		}
+	}
+
+	if s.closed {
+		event.done()
+		return
+	}
+
+	s.queue = append(s.queue, event)
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) run() {
+	for {
+		s.mu.Lock()
+
+		for !s.closed && len(s.queue) == 0 {
+			s.cond.Wait()
+		}
+
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.cond.Broadcast() // wake emitters blocked on a full queue
+
+		s.mu.Unlock()
+
+		s.invoke(event.record)
+		event.done()
+	}
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+
+	for _, event := range s.queue {
+		event.done()
+	}
+
+	s.queue = nil
+	s.cond.Broadcast()
+}
+
+// pointerOf yields the identity of a function value, the same way the
+// underlying event emitter compares listeners.
+func pointerOf(fn func(...any)) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&fn))
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DefaultRequestTimeout is the timeout applied to requests whose context has
+// no deadline.
+const DefaultRequestTimeout = time.Second * 10
+
+var (
+	// ErrNoRequestHandler is returned when a request is made for which no
+	// handler has been registered.
+	ErrNoRequestHandler = errors.New("no request handler registered")
+
+	// ErrRequestHandlerExists is returned when registering a handler for a
+	// request that already has one.
+	ErrRequestHandlerExists = errors.New("request handler already registered")
+
+	// ErrUnexpectedRequestType is returned when a request or its response
+	// does not have the type expected by a typed handler or caller.
+	ErrUnexpectedRequestType = errors.New("unexpected request type")
+)
+
+// RequestHandler handles a request made with EventBus.Request, and yields a
+// response or an error.
+type RequestHandler func(ctx context.Context, request any) (any, error)
+
+// HandleRequest registers the handler for the named request. There can be
+// only one handler for a request.
+func (b *EventBus) HandleRequest(name string, handler RequestHandler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, found := b.requestHandlers[name]; found {
+		return fmt.Errorf("%w: %q", ErrRequestHandlerExists, name)
+	}
+
+	if b.requestHandlers == nil {
+		b.requestHandlers = make(map[string]RequestHandler)
+	}
+
+	b.requestHandlers[name] = handler
+
+	return nil
+}
+
+// RemoveRequestHandler removes the handler of the named request.
+func (b *EventBus) RemoveRequestHandler(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.requestHandlers, name)
+}
+
+// Request calls the handler of the named request and waits for its response.
+// If the context has no deadline, DefaultRequestTimeout applies, measured on
+// the clock of the bus. The context given to the handler is cancelled when
+// the request times out, with context.DeadlineExceeded as its cause.
+func (b *EventBus) Request(ctx context.Context, name string, request any) (any, error) {
+	b.mu.Lock()
+	handler, found := b.requestHandlers[name]
+	clock := b.timeSource()
+	b.mu.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrNoRequestHandler, name)
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		// the timeout elapses on the clock of the bus, not on the wall clock
+		deadline := clock.After(DefaultRequestTimeout)
+
+		go func() {
+			select {
+			case <-deadline:
+				cancel(context.DeadlineExceeded)
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	type reply struct {
+		response any
+		err      error
+	}
+
+	replies := make(chan reply, 1)
+
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				replies <- reply{err: fmt.Errorf("request %q: handler panic: %v", name, recovered)}
+			}
+		}()
+
+		response, err := handler(ctx, request)
+		replies <- reply{response, err}
+	}()
+
+	select {
+	case r := <-replies:
+		return r.response, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request %q: %w", name, context.Cause(ctx))
+	}
+}
+
+// RequestName yields the name under which typed requests of type Req are
+// handled.
+func RequestName[Req any]() string {
+	return reflect.TypeOf((*Req)(nil)).Elem().String()
+}
+
+// HandleTypedRequest registers a handler for requests of type Req, named
+// after the type with RequestName.
+func HandleTypedRequest[Req, Resp any](b *EventBus, handler func(context.Context, Req) (Resp, error)) error {
+	return b.HandleRequest(RequestName[Req](), func(ctx context.Context, request any) (any, error) {
+		typed, ok := request.(Req)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected %s, got %T", ErrUnexpectedRequestType, RequestName[Req](), request)
+		}
+
+		return handler(ctx, typed)
+	})
+}
+
+// TypedRequest makes a request of type Req, and yields the response as type
+// Resp.
+func TypedRequest[Req, Resp any](ctx context.Context, b *EventBus, request Req) (Resp, error) {
+	var typed Resp
+
+	response, err := b.Request(ctx, RequestName[Req](), request)
+	if err != nil {
+		return typed, err
+	}
+
+	typed, ok := response.(Resp)
+	if !ok && response != nil {
+		return typed, fmt.Errorf("%w: expected response %s, got %T", ErrUnexpectedRequestType, RequestName[Resp](), response)
+	}
+
+	return typed, nil
+}
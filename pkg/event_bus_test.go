@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventBus_History(t *testing.T) {
+	bus := NewEventBus(3)
+
+	for _, event := range []string{"a", "b", "c", "d"} {
+		bus.Emit(event, event).Wait()
+	}
+
+	history := bus.History()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 retained events, got %d", len(history))
+	}
+
+	if history[0].Name != "b" || history[0].Sequence != 2 {
+		t.Errorf("expected oldest retained event to be 'b' (2), got %q (%d)", history[0].Name, history[0].Sequence)
+	}
+
+	if since := bus.HistorySince(3); len(since) != 1 || since[0].Name != "d" {
+		t.Errorf("unexpected history since sequence 3: %v", since)
+	}
+
+	bus.SetHistorySize(1)
+	if history = bus.History(); len(history) != 1 || history[0].Name != "d" {
+		t.Errorf("unexpected history after shrinking: %v", history)
+	}
+}
+
+func TestEventBus_OnWithReplay(t *testing.T) {
+	bus := NewEventBus(DefaultEventHistorySize)
+
+	bus.Emit("x", 1).Wait()
+	bus.Emit("y", 2).Wait()
+	bus.Emit("x", 3).Wait()
+
+	received := make(chan any, 4)
+	handler := func(args ...any) { received <- args[0] }
+
+	bus.OnWithReplay("x", handler, ReplayAll).Wait()
+
+	if got := len(received); got != 2 {
+		t.Fatalf("expected 2 replayed events, got %d", got)
+	}
+
+	if first, second := <-received, <-received; first != 1 || second != 3 {
+		t.Errorf("replayed events out of order: %v, %v", first, second)
+	}
+
+	bus.Emit("x", 4).Wait()
+
+	if got := <-received; got != 4 {
+		t.Errorf("expected live event after replay, got %v", got)
+	}
+
+	late := make(chan any, 4)
+	bus.OnWithReplay("x", func(args ...any) { late <- args[0] }, 3).Wait()
+
+	if got := len(late); got != 1 {
+		t.Fatalf("expected 1 replayed event since sequence 3, got %d", got)
+	}
+}
+
+func TestEventBus_DeliveryModes(t *testing.T) {
+	bus := NewEventBus(DefaultEventHistorySize)
+
+	var syncOrder []int
+	bus.Subscribe("sync", func(args ...any) { syncOrder = append(syncOrder, args[0].(int)) }, WithDeliveryMode(DeliverySync))
+
+	for i := 0; i < 5; i++ {
+		bus.Emit("sync", i) // synchronous handlers have run when Emit returns
+	}
+
+	if len(syncOrder) != 5 {
+		t.Fatalf("expected 5 synchronously delivered events, got %d", len(syncOrder))
+	}
+
+	for i, v := range syncOrder {
+		if i != v {
+			t.Fatalf("synchronous delivery out of order: %v", syncOrder)
+		}
+	}
+
+	received := make(chan int, 100)
+	bus.Subscribe("ordered", func(args ...any) { received <- args[0].(int) }, WithDeliveryMode(DeliveryAsyncOrdered))
+
+	var last *sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		last = bus.Emit("ordered", i)
+	}
+
+	last.Wait()
+
+	for i := 0; i < 100; i++ {
+		if got := <-received; got != i {
+			t.Fatalf("ordered delivery out of order: expected %d, got %d", i, got)
+		}
+	}
+
+	// events emitted concurrently are delivered in the order of their
+	// sequence numbers
+	sequences := make(chan uint64, 400)
+	bus.SubscribeRecords("concurrent", func(record EventRecord) { sequences <- record.Sequence }, WithDeliveryMode(DeliveryAsyncOrdered))
+
+	var emitters sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		emitters.Add(1)
+		go func() {
+			defer emitters.Done()
+
+			for j := 0; j < 100; j++ {
+				bus.Emit("concurrent", j)
+			}
+		}()
+	}
+
+	emitters.Wait()
+
+	var previous uint64
+	for i := 0; i < 400; i++ {
+		sequence := <-sequences
+		if sequence <= previous {
+			t.Fatalf("concurrent delivery out of order: %d after %d", sequence, previous)
+		}
+
+		previous = sequence
+	}
+}
+
+func TestEventBus_DropPolicy(t *testing.T) {
+	bus := NewEventBus(DefaultEventHistorySize)
+
+	release := make(chan struct{})
+	handled := make(chan int, 10)
+
+	sub := bus.Subscribe("slow", func(args ...any) {
+		<-release
+		handled <- args[0].(int)
+	}, WithDeliveryMode(DeliveryAsyncOrdered), WithQueueSize(2), WithOverflowPolicy(OverflowDropOldest))
+
+	// the first event is taken off the queue by the handler, which blocks
+	bus.Emit("slow", 0)
+	for sub.Queued() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wgs []*sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wgs = append(wgs, bus.Emit("slow", i))
+	}
+
+	close(release)
+
+	for _, wg := range wgs {
+		wg.Wait()
+	}
+
+	if sub.Dropped() != 2 || bus.Dropped()["slow"] != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", sub.Dropped())
+	}
+
+	for _, expected := range []int{0, 3, 4} {
+		if got := <-handled; got != expected {
+			t.Errorf("expected event %d to be handled, got %d", expected, got)
+		}
+	}
+}
+
+func TestEventBus_Forward(t *testing.T) {
+	a, b, c := NewEventBus(DefaultEventHistorySize), NewEventBus(DefaultEventHistorySize), NewEventBus(DefaultEventHistorySize)
+
+	// the buses forward to each other, in a cycle
+	a.Forward(b, "a", "*")
+	b.Forward(a, "b", "*")
+	b.Forward(c, "b", "*")
+	c.Forward(a, "c", "*")
+
+	a.Emit("ping").Wait()
+
+	for name, bus := range map[string]*EventBus{"a": a, "b": b, "c": c} {
+		if history := bus.History(); len(history) != 1 {
+			t.Errorf("expected the event to be emitted once on bus %s, got %v", name, history)
+		}
+	}
+
+	if history := c.History(); len(history) == 1 && history[0].Origin != "b" {
+		t.Errorf("unexpected origin %q", history[0].Origin)
+	}
+}
+
+func TestEventBus_Request(t *testing.T) {
+	bus := NewEventBus(DefaultEventHistorySize)
+
+	type ping struct{ n int }
+	type pong struct{ n int }
+
+	err := HandleTypedRequest(bus, func(ctx context.Context, req ping) (pong, error) {
+		return pong{req.n + 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = HandleTypedRequest(bus, func(context.Context, ping) (pong, error) { return pong{}, nil }); !errors.Is(err, ErrRequestHandlerExists) {
+		t.Errorf("expected duplicate handler to be rejected, got %v", err)
+	}
+
+	resp, err := TypedRequest[ping, pong](context.Background(), bus, ping{1})
+	if err != nil || resp.n != 2 {
+		t.Errorf("unexpected response %v, %v", resp, err)
+	}
+
+	if _, err = bus.Request(context.Background(), "missing", nil); !errors.Is(err, ErrNoRequestHandler) {
+		t.Errorf("expected missing handler error, got %v", err)
+	}
+
+	_ = bus.HandleRequest("slow", func(ctx context.Context, _ any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if _, err = bus.Request(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected request to time out, got %v", err)
+	}
+}
@@ -6,7 +6,10 @@ const (
 	EventServiceInitialized = "service initialized"
 	EventServiceEventsBound = "service events bound"
 	EventServiceLoggerBound = "service logger bound"
+	EventServiceReplaced    = "service replaced"
+	EventServiceError       = "service error"
 
+	EventRuntimeReady             = "runtime ready"
 	EventRuntimeRunLoopInitiated  = "runtime begin"
 	EventRuntimeShutdownInitiated = "runtime shutdown"
 
@@ -1,10 +1,11 @@
 package pkg
 
 import (
+	"context"
 	"io"
+	"log/slog"
 	"sync"
 
-	ee "github.com/gravestench/eventemitter"
 	"github.com/rs/zerolog"
 )
 
@@ -21,16 +22,80 @@ type IsRuntime interface {
 	// Remove a specific service from the IsRuntime.
 	Remove(IsRuntimeService) *sync.WaitGroup
 
+	// Replace a service with another, without stopping the IsRuntime.
+	Replace(old, replacement IsRuntimeService) error
+
+	// ReplaceContext replaces a service with another, giving up if the
+	// context is done before the replacement is initialized.
+	ReplaceContext(ctx context.Context, old, replacement IsRuntimeService) error
+
+	// AddNamed adds a single service under the given instance name.
+	AddNamed(name string, service IsRuntimeService) *sync.WaitGroup
+
+	// Service looks up a service by the name it was added under.
+	Service(name string) (IsRuntimeService, bool)
+
+	// StateOf returns where the service is in its lifecycle.
+	StateOf(service IsRuntimeService) ServiceState
+
+	// DependencyGraph returns the services and their dependencies.
+	DependencyGraph() DependencyGraph
+
+	// ServicesTagged returns the services that have every one of the tags
+	// of their metadata.
+	ServicesTagged(tags ...string) []IsRuntimeService
+
 	// Services returns a pointer to a slice of interfaces representing the
 	// services currently managed by the service IsRuntime.
 	Services() []IsRuntimeService
 
 	SetLogLevel(level zerolog.Level)
 	SetLogDestination(dst io.Writer)
+	SetLogFormat(format LogFormat)
+
+	// SetServiceLogLevel and SetServiceLogDestination override the log
+	// level and destination of the services whose name matches a pattern.
+	SetServiceLogLevel(pattern string, level zerolog.Level)
+	SetServiceLogDestination(pattern string, dst io.Writer)
+
+	// ServiceLogLevel returns the effective log level of the named service.
+	ServiceLogLevel(name string) zerolog.Level
+
+	// Logs returns the captured log records matching the query, if log
+	// capture is enabled.
+	Logs(query LogQuery) []LogRecord
+
+	// Events yields the event bus of the runtime.
+	Events() *EventBus
+
+	// Clock yields the clock used by the runtime for all timing.
+	Clock() Clock
+
+	// WorkerPool returns the named worker pool, creating it for the owner
+	// with the given number of workers and queue depth.
+	WorkerPool(owner IsRuntimeService, name string, size, queueDepth int) *WorkerPool
+
+	// Ready returns true once every service is initialized and started,
+	// and WaitReady blocks until then.
+	Ready() bool
+	WaitReady(ctx context.Context) error
 
-	Events() *ee.EventEmitter
+	// NotReady returns the names of the services that are not initialized
+	// or not started yet.
+	NotReady() []string
+
+	// ReportError reports an error of a service. A fatal error shuts the
+	// runtime down.
+	ReportError(service IsRuntimeService, err error, fatal bool)
+
+	// Err returns the errors reported so far, joined.
+	Err() error
 
 	Shutdown() *sync.WaitGroup
+
+	// ShutdownContext shuts the runtime down, and returns the errors
+	// reported to it.
+	ShutdownContext(ctx context.Context) error
 }
 
 // IsRuntimeService represents a generic service within a runtime.
@@ -69,6 +134,34 @@ type HasDependencies interface {
 	ResolveDependencies(IsRuntime)
 }
 
+// HasDeclaredDependencies represents a service that declares the names of
+// the services it depends on.
+//
+// The Runtime waits for the declared services to be running before it
+// initializes the service, and includes the declared dependencies in its
+// dependency graph. It can be implemented along with HasDependencies.
+type HasDeclaredDependencies interface {
+	IsRuntimeService
+
+	// DependsOn returns the names of the services this service depends on.
+	DependsOn() []string
+}
+
+// HasOptionalDependencies represents a service that uses other services if
+// they are present, and does without them otherwise.
+//
+// The Runtime waits for the optional dependencies to be running before it
+// initializes the service, but only up to a grace period, after which it
+// initializes the service anyway. The service can then look them up with
+// IsRuntime.Service in its Init.
+type HasOptionalDependencies interface {
+	IsRuntimeService
+
+	// OptionalDependsOn returns the names of the services this service
+	// uses if they are present.
+	OptionalDependsOn() []string
+}
+
 // HasLogger is an interface for components that require a logger instance.
 //
 // The HasLogger interface represents components that depend on a logger for
@@ -81,6 +174,21 @@ type HasLogger interface {
 	Logger() *zerolog.Logger
 }
 
+// HasSlogLogger is an interface for components that use the standard library
+// structured logger instead of zerolog.
+//
+// The runtime binds a *slog.Logger that writes through the same output as
+// the zerolog loggers of the runtime, so it follows the same per-service
+// naming, log levels, format and destinations, and is bound again whenever
+// those change. A service may implement both HasLogger and HasSlogLogger.
+type HasSlogLogger interface {
+	IsRuntimeService
+	// BindSlogLogger sets the slog logger instance for the component.
+	BindSlogLogger(logger *slog.Logger)
+	// SlogLogger yields the slog logger instance for the component.
+	SlogLogger() *slog.Logger
+}
+
 // HasGracefulShutdown is an interface for services that require graceful shutdown handling.
 //
 // The HasGracefulShutdown interface extends the IsRuntimeService interface and adds
@@ -93,6 +201,49 @@ type HasGracefulShutdown interface {
 	OnShutdown()
 }
 
+// HasRequestHandlers is an optional interface for services that answer
+// requests made by other services over the runtime event bus.
+//
+// When the service is added to the runtime, each handler is registered with
+// EventBus.HandleRequest under the name it is mapped to, and the handlers are
+// removed again when the service is removed. This lets other services call
+// it with EventBus.Request without holding a reference to it.
+type HasRequestHandlers interface {
+	IsRuntimeService
+
+	// RequestHandlers returns the request handlers of the service, by
+	// request name.
+	RequestHandlers() map[string]RequestHandler
+}
+
+// HasEventReplay is an optional interface for services that want to learn
+// about events that were emitted before they were added to the runtime.
+//
+// When the runtime binds the event handler interfaces of a service that
+// implements HasEventReplay, the retained event history with a sequence
+// number greater than the one returned by ReplayEventsSince is replayed to
+// the bound handlers. Return ReplayAll to replay the entire retained history.
+type HasEventReplay interface {
+	IsRuntimeService
+
+	// ReplayEventsSince returns the sequence number after which events
+	// should be replayed.
+	ReplayEventsSince() uint64
+}
+
+// HasMetadata is an optional interface for services that describe
+// themselves beyond their name, with a version, a description, an owner and
+// tags.
+//
+// The metadata is logged when the service is added, and services can be
+// queried by tag with Runtime.ServicesTagged.
+type HasMetadata interface {
+	IsRuntimeService
+
+	// Metadata returns the metadata of the service.
+	Metadata() Metadata
+}
+
 // EventHandlerServiceAdded is an optional interface. If implemented, it will automatically bind to the
 // "Service Added" runtime event, allowing the object to respond when a new service is added.
 type EventHandlerServiceAdded interface {
@@ -130,6 +281,7 @@ type EventHandlerServiceLoggerBound interface {
 // EventHandlerRuntimeRunLoopInitiated is an optional interface. If implemented, it will automatically bind to the
 // "Runtime Run Loop Initiated" runtime event, enabling the implementor to respond when the runtime run loop is initiated.
 // When the event is emitted, the declared method will be called and passed the arguments from the emitter.
+// The event is emitted once every service is initialized and started, see HasStartHook.
 type EventHandlerRuntimeRunLoopInitiated interface {
 	OnRuntimeRunLoopInitiated(args ...interface{})
 }
@@ -154,3 +306,24 @@ type EventHandlerDependencyResolutionStarted interface {
 type EventHandlerDependencyResolutionEnded interface {
 	OnDependencyResolutionEnded(args ...interface{})
 }
+
+// EventHandlerServiceReplaced is an optional interface. If implemented, it will automatically bind to the
+// "Service Replaced" runtime event, enabling the implementor to respond when a service is replaced by another.
+// When the event is emitted, the declared method will be called and passed the old and the new service.
+type EventHandlerServiceReplaced interface {
+	OnServiceReplaced(args ...interface{})
+}
+
+// EventHandlerServiceError is an optional interface. If implemented, it will automatically bind to the
+// "Service Error" runtime event, enabling the implementor to respond when an error of a service is reported.
+// When the event is emitted, the declared method will be called and passed the service, the error, and whether it is fatal.
+type EventHandlerServiceError interface {
+	OnServiceError(args ...interface{})
+}
+
+// EventHandlerRuntimeReady is an optional interface. If implemented, it will automatically bind to the
+// "Runtime Ready" runtime event, enabling the implementor to respond when every service is initialized and started.
+// When the event is emitted, the declared method will be called and passed the arguments from the emitter.
+type EventHandlerRuntimeReady interface {
+	OnRuntimeReady(args ...interface{})
+}
@@ -0,0 +1,417 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the format of the timestamp in the names of rotated
+// files. It sorts lexically, and contains no characters that are invalid in
+// file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+const compressSuffix = ".gz"
+
+// startedSuffix is the suffix of the file, next to the log file, that holds
+// the time the log file was started, see Config.RotateEvery.
+const startedSuffix = ".started"
+
+var _ io.WriteCloser = &File{}
+
+// Config describes where a log file is written, and when it is rotated.
+type Config struct {
+	// Path is the path of the log file. Rotated files are kept next to it,
+	// named after it with the time of the rotation, eg "app-<time>.log".
+	Path string
+
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables rotation by size.
+	MaxSize int64
+
+	// RotateEvery is the interval after which the file is rotated. Zero
+	// disables rotation by time. The time the file was started is kept
+	// next to it, in a file named after it with the ".started" suffix, so
+	// that restarting the process or reopening the file does not postpone
+	// its rotation. An existing file without one counts from its last
+	// modification.
+	RotateEvery time.Duration
+
+	// Compress rotated files with gzip.
+	Compress bool
+
+	// MaxBackups is the number of rotated files to keep. Zero keeps all.
+	MaxBackups int
+
+	// MaxAge is how long rotated files are kept. Zero keeps them forever.
+	MaxAge time.Duration
+}
+
+// File is an io.WriteCloser that writes to a log file, and rotates it by
+// size or by time. It can be used as the log destination of a runtime, or
+// of individual services.
+type File struct {
+	config Config
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+	signals  chan os.Signal
+
+	// cleanup tracks the background cleanups, and lastCleanup is closed
+	// once the most recent one has completed
+	cleanup     sync.WaitGroup
+	lastCleanup chan struct{}
+}
+
+// Open opens the log file described by the config, creating it and its
+// directory if needed.
+func Open(config Config) (*File, error) {
+	if config.Path == "" {
+		return nil, errors.New("log file path is required")
+	}
+
+	f := &File{config: config}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write writes to the log file, rotating it first if it is due.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	// a previous reopen or rotation may have failed to open the file
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Rotate moves the current log file aside and opens a new one.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+// Reopen closes and reopens the log file, for when it has been moved by an
+// external tool such as logrotate.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+
+		f.file = nil
+	}
+
+	return f.open()
+}
+
+// ReopenOnSignal reopens the log file whenever one of the given signals is
+// received, eg syscall.SIGHUP, until the file is closed.
+func (f *File) ReopenOnSignal(signals ...os.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	if f.signals != nil {
+		signal.Notify(f.signals, signals...)
+		return
+	}
+
+	f.signals = make(chan os.Signal, 1)
+	signal.Notify(f.signals, signals...)
+
+	go func(received chan os.Signal) {
+		for range received {
+			_ = f.Reopen()
+		}
+	}(f.signals)
+}
+
+// Close closes the log file, and waits for the compression and removal of
+// rotated files to complete.
+func (f *File) Close() error {
+	f.mu.Lock()
+
+	f.closed = true
+
+	if f.signals != nil {
+		signal.Stop(f.signals)
+		close(f.signals)
+		f.signals = nil
+	}
+
+	var err error
+
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+
+	f.mu.Unlock()
+
+	f.cleanup.Wait()
+
+	return err
+}
+
+// Backups returns the paths of the rotated files, oldest first.
+func (f *File) Backups() ([]string, error) {
+	dir, prefix, ext := f.nameParts()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]string, 0)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix+"-"), compressSuffix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+
+		backups = append(backups, filepath.Join(dir, name))
+	}
+
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.config.Path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.started(info)
+
+	return nil
+}
+
+// started returns the time the log file was started, as kept next to it. A
+// new file is started now, and an existing file without a start time at its
+// last modification. The start time is only kept for rotation by time.
+func (f *File) started(info os.FileInfo) time.Time {
+	if f.config.RotateEvery <= 0 {
+		return time.Now()
+	}
+
+	path := f.config.Path + startedSuffix
+
+	if info.Size() > 0 {
+		if data, err := os.ReadFile(path); err == nil {
+			if started, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data))); err == nil {
+				return started
+			}
+		}
+	}
+
+	started := time.Now()
+
+	if info.Size() > 0 {
+		started = info.ModTime()
+	}
+
+	// rotating by time still works for this process if it cannot be kept
+	_ = os.WriteFile(path, []byte(started.Format(time.RFC3339Nano)), 0o644)
+
+	return started
+}
+
+// due returns true when writing n more bytes requires the file to be
+// rotated first.
+func (f *File) due(n int64) bool {
+	if f.config.MaxSize > 0 && f.size > 0 && f.size+n > f.config.MaxSize {
+		return true
+	}
+
+	if f.config.RotateEvery > 0 && time.Since(f.openedAt) >= f.config.RotateEvery {
+		return true
+	}
+
+	return false
+}
+
+func (f *File) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+
+		f.file = nil
+	}
+
+	dir, prefix, ext := f.nameParts()
+	backup := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, time.Now().Format(backupTimeFormat), ext))
+
+	if err := os.Rename(f.config.Path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	// compressing and removing old files can be slow, so it is done in
+	// the background, each cleanup waiting for the previous one without
+	// holding up writes
+	previous, done := f.lastCleanup, make(chan struct{})
+	f.lastCleanup = done
+
+	f.cleanup.Add(1)
+
+	go func() {
+		defer f.cleanup.Done()
+		defer close(done)
+
+		if previous != nil {
+			<-previous
+		}
+
+		if f.config.Compress {
+			_ = compress(backup)
+		}
+
+		_ = f.removeExpired()
+	}()
+
+	return nil
+}
+
+// removeExpired removes the rotated files beyond MaxBackups or older than
+// MaxAge.
+func (f *File) removeExpired() error {
+	if f.config.MaxBackups <= 0 && f.config.MaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+
+	for idx, backup := range backups {
+		expired := f.config.MaxBackups > 0 && idx < len(backups)-f.config.MaxBackups
+
+		if !expired && f.config.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil {
+				expired = time.Since(info.ModTime()) > f.config.MaxAge
+			}
+		}
+
+		if expired {
+			_ = os.Remove(backup)
+		}
+	}
+
+	return nil
+}
+
+// nameParts splits the path of the log file into its directory, the base
+// name without extension, and the extension.
+func (f *File) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(f.config.Path)
+	base := filepath.Base(f.config.Path)
+	ext = filepath.Ext(base)
+
+	return dir, strings.TrimSuffix(base, ext), ext
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(path + compressSuffix)
+		return err
+	}
+
+	return os.Remove(path)
+}
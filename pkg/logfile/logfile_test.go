@@ -0,0 +1,155 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	f, err := Open(Config{Path: path, MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err = f.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 retained backups, got %v", backups)
+	}
+
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".log.gz") {
+			t.Errorf("expected backup %q to be compressed", backup)
+		}
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "0123456789" {
+		t.Errorf("unexpected content of current log file: %q", data)
+	}
+
+	if _, err = f.Write([]byte("x")); err == nil {
+		t.Error("expected write to closed file to fail")
+	}
+}
+
+func TestFile_RotateEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file was last written before the process restarted
+	modified := time.Now().Add(-time.Hour * 2)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(Config{Path: path, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write([]byte("new"))
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if backups, _ := f.Backups(); len(backups) != 1 {
+		t.Fatalf("expected the file to be rotated, got backups %v", backups)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("unexpected content of current log file: %q", data)
+	}
+}
+
+func TestFile_RotateEveryReopened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f, err := Open(Config{Path: path, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write([]byte("old"))
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file was started two hours ago, and written until just now
+	started := time.Now().Add(-time.Hour * 2)
+	if err = os.WriteFile(path+startedSuffix, []byte(started.Format(time.RFC3339Nano)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if f, err = Open(Config{Path: path, RotateEvery: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write([]byte("new"))
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if backups, _ := f.Backups(); len(backups) != 1 {
+		t.Fatalf("expected reopening not to postpone the rotation, got backups %v", backups)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("unexpected content of current log file: %q", data)
+	}
+}
+
+func TestFile_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	_, _ = f.Write([]byte("before"))
+
+	// an external tool moves the file aside
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write([]byte("after"))
+
+	if data, _ := os.ReadFile(path); string(data) != "after" {
+		t.Errorf("expected reopened file to contain only new writes, got %q", data)
+	}
+}
@@ -0,0 +1,67 @@
+// Package logtest provides test helpers that assert on the log records
+// captured by a runtime.
+package logtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+// Capture enables log capture on the runtime, and yields the capture. The
+// records captured so far are discarded.
+func Capture(rt *pkg.Runtime) *pkg.LogCapture {
+	if rt.LogCapture() == nil {
+		rt.SetLogCapture(0)
+	}
+
+	capture := rt.LogCapture()
+	capture.Reset()
+
+	return capture
+}
+
+// Find returns the captured records matching the query whose message
+// contains the given text.
+func Find(rt pkg.IsRuntime, query pkg.LogQuery, contains string) []pkg.LogRecord {
+	found := make([]pkg.LogRecord, 0)
+
+	for _, record := range rt.Logs(query) {
+		if strings.Contains(record.Message, contains) {
+			found = append(found, record)
+		}
+	}
+
+	return found
+}
+
+// AssertLogged fails the test if no captured record matches the query with
+// a message containing the given text.
+func AssertLogged(t testing.TB, rt pkg.IsRuntime, query pkg.LogQuery, contains string) {
+	t.Helper()
+
+	if len(Find(rt, query, contains)) == 0 {
+		t.Errorf("expected a log record containing %q matching %+v, got:\n%s", contains, query, dump(rt.Logs(query)))
+	}
+}
+
+// AssertNotLogged fails the test if a captured record matches the query with
+// a message containing the given text.
+func AssertNotLogged(t testing.TB, rt pkg.IsRuntime, query pkg.LogQuery, contains string) {
+	t.Helper()
+
+	if found := Find(rt, query, contains); len(found) > 0 {
+		t.Errorf("expected no log record containing %q matching %+v, got:\n%s", contains, query, dump(found))
+	}
+}
+
+func dump(records []pkg.LogRecord) string {
+	lines := make([]string, 0, len(records))
+
+	for _, record := range records {
+		lines = append(lines, "\t["+record.Service+"] "+record.Level.String()+": "+record.Message)
+	}
+
+	return strings.Join(lines, "\n")
+}
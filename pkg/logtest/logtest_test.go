@@ -0,0 +1,49 @@
+package logtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+type loggingService struct {
+	logger *zerolog.Logger
+}
+
+func (s *loggingService) Init(_ pkg.IsRuntime)              {}
+func (s *loggingService) Name() string                      { return "db.users" }
+func (s *loggingService) BindLogger(logger *zerolog.Logger) { s.logger = logger }
+func (s *loggingService) Logger() *zerolog.Logger           { return s.logger }
+
+func TestCapture(t *testing.T) {
+	rt := pkg.New("test")
+	rt.SetLogLevel(zerolog.InfoLevel)
+	rt.SetLogDestination(&bytes.Buffer{})
+
+	Capture(rt)
+
+	svc := &loggingService{}
+	rt.Add(svc).Wait()
+
+	start := time.Now()
+
+	svc.Logger().Info().Msg("connected")
+	svc.Logger().Error().Str("table", "users").Msg("query failed")
+
+	AssertLogged(t, rt, pkg.LogQuery{Service: "db.*"}, "connected")
+	AssertLogged(t, rt, pkg.LogQuery{Service: "db.users", Level: zerolog.ErrorLevel, Since: start}, "query failed")
+	AssertNotLogged(t, rt, pkg.LogQuery{Service: "db.users", Level: zerolog.ErrorLevel}, "connected")
+
+	records := Find(rt, pkg.LogQuery{Service: "db.users"}, "query failed")
+	if len(records) != 1 || records[0].Fields["table"] != "users" {
+		t.Errorf("expected captured fields, got %+v", records)
+	}
+
+	if limited := rt.Logs(pkg.LogQuery{Service: "db.users", Limit: 1}); len(limited) != 1 || limited[0].Message != "query failed" {
+		t.Errorf("expected the most recent record, got %+v", limited)
+	}
+}
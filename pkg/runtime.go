@@ -1,15 +1,15 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"time"
 
-	ee "github.com/gravestench/eventemitter"
 	"github.com/rs/zerolog"
 
 	"github.com/gravestench/runtime/pkg/events"
@@ -25,242 +25,768 @@ type Runtime struct {
 	logger    *zerolog.Logger
 	logOutput io.Writer
 	logLevel  zerolog.Level
-	events    *ee.EventEmitter
+	logFormat LogFormat
+	events    *EventBus
+
+	logTimeFormat string
+
+	logCapture *LogCapture
+
+	logMu        sync.Mutex
+	logOverrides []logOverride
+	baseLogger   *zerolog.Logger
+
+	// the log overrides of the parent runtime, which apply unless the
+	// runtime has its own
+	inheritedOverrides []logOverride
+
+	initOnce     sync.Once
+	servicesMu   sync.RWMutex
+	names        map[IsRuntimeService]string
+	states       map[IsRuntimeService]ServiceState
+	tasks        map[IsRuntimeService]*TaskGroup
+	goroutines   map[IsRuntimeService]map[uint64]string
+	leaks        []GoroutineLeak
+	pools        map[string]*WorkerPool
+	requests     map[IsRuntimeService][]string
+	handlers     map[IsRuntimeService][]*Subscription
+	lookups      map[IsRuntimeService]map[string]bool
+	lazies       map[IsRuntimeService][]lazyDependency
+	detectLeaks  bool
+	namingPolicy NameConflictPolicy
+
+	signals         []os.Signal
+	shutdownTimeout time.Duration
+	startupTimeout  time.Duration
+
+	startDone       chan struct{}
+	startOnce       sync.Once
+	startErr        error
+	startedServices map[IsRuntimeService]bool
+
+	errMu    sync.Mutex
+	errs     []error
+	failed   chan struct{}
+	failOnce sync.Once
+
+	optionalDependencyTimeout time.Duration
+	taskShutdownTimeout       time.Duration
+	clock                     Clock
+	registryHooks             []RegistryHooks
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	treeMu         sync.Mutex
+	parent         *Runtime
+	forwardEvents  []string
+	stopForwarding func()
 }
 
-// New creates a new instance of a Runtime.
-func New(args ...string) *Runtime {
-	name := "Runtime"
-
-	if len(args) > 0 {
-		name = strings.Join(args, " ")
+// New creates a new instance of a Runtime with the given name, and applies
+// the options to it before it initializes itself.
+func New(name string, options ...Option) *Runtime {
+	if name == "" {
+		name = "Runtime"
 	}
 
 	r := &Runtime{
-		name:      name,
-		events:    ee.New(),
-		logOutput: os.Stdout,
+		name:       name,
+		events:     NewEventBus(DefaultEventHistorySize),
+		logOutput:  os.Stdout,
+		logLevel:   zerolog.InfoLevel,
+		signals:    []os.Signal{os.Interrupt},
+		clock:      SystemClock,
+		stopped:    make(chan struct{}),
+		startDone:  make(chan struct{}),
+		failed:     make(chan struct{}),
+		names:      make(map[IsRuntimeService]string),
+		states:     make(map[IsRuntimeService]ServiceState),
+		tasks:      make(map[IsRuntimeService]*TaskGroup),
+		goroutines: make(map[IsRuntimeService]map[uint64]string),
+		pools:      make(map[string]*WorkerPool),
+		requests:   make(map[IsRuntimeService][]string),
+		handlers:   make(map[IsRuntimeService][]*Subscription),
+		lookups:    make(map[IsRuntimeService]map[string]bool),
+		lazies:     make(map[IsRuntimeService][]lazyDependency),
+
+		startedServices: make(map[IsRuntimeService]bool),
+
+		optionalDependencyTimeout: DefaultOptionalDependencyTimeout,
+		taskShutdownTimeout:       DefaultTaskShutdownTimeout,
 	}
 
+	for _, option := range options {
+		option(r)
+	}
+
+	r.events.SetClock(r.clock)
+
 	// the runtime itself is a service that binds handlers to its own events
 	r.Add(r)
 
 	return r
 }
 
-func (r *Runtime) Init(_ IsRuntime) {
-	if r.services != nil {
-		return
+// Init initializes the runtime. When the runtime is added to another runtime,
+// it becomes a child of it.
+func (r *Runtime) Init(rt IsRuntime) {
+	r.initOnce.Do(r.init)
+
+	if parent, ok := unwrapRuntime(rt).(*Runtime); ok && parent != r {
+		r.attach(parent)
 	}
+}
 
-	r.logger = r.newLogger(r, zerolog.InfoLevel, r.logOutput)
+func (r *Runtime) init() {
+	logger := r.newLogger(r, r.logLevel, r.logOutput)
 
-	r.logger.Info().Msgf("initializing")
+	r.logMu.Lock()
+	r.logger = logger
+	r.logMu.Unlock()
+
+	r.log().Info().Msgf("initializing")
 
 	r.quit = make(chan os.Signal, 1)
-	signal.Notify(r.quit, os.Interrupt)
 
+	r.servicesMu.Lock()
 	r.services = make([]IsRuntimeService, 0)
+	r.servicesMu.Unlock()
 }
 
-// Add a single service to the Runtime manager.
+// Add a single service to the Runtime manager, under its own name.
 func (r *Runtime) Add(service IsRuntimeService) *sync.WaitGroup {
+	return r.AddNamed(service.Name(), service)
+}
+
+// AddNamed adds a single service to the Runtime manager under the given
+// instance name, eg to run several instances of the same service, such as
+// "cache/users" and "cache/sessions". When the name is already taken, the
+// name conflict policy of the runtime applies.
+func (r *Runtime) AddNamed(name string, service IsRuntimeService) *sync.WaitGroup {
 	r.Init(nil) // always ensure runtime is init
-	r.bindEventHandlerInterfaces(service)
 
 	var wg sync.WaitGroup
 
+	name, existing, err := r.register(name, service)
+	if err != nil {
+		r.log().Error().Err(err).Msgf("adding service %q", name)
+		r.recordError(&ServiceError{Service: name, Err: err})
+
+		return &wg
+	}
+
+	if existing != nil {
+		// like any other service, the replacement is added in the
+		// background, and the wait group is done once it is in place
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := r.Replace(existing, service); err != nil {
+				r.log().Error().Err(err).Msgf("adding service %q", name)
+				r.recordError(&ServiceError{Service: name, Err: err})
+			}
+		}()
+
+		return &wg
+	}
+
+	// a child runtime handles its own events, not those of its parent
+	if child, isRuntime := service.(*Runtime); !isRuntime || child == r {
+		r.bindEventHandlerInterfaces(service)
+	}
+
+	r.bindRequestHandlers(service)
+
 	if service != r {
-		r.logger.Info().Msgf("preparing service %q", service.Name())
+		r.log().Info().Msgf("preparing service %q", name)
+		r.logMetadata(name, service)
 	}
 
 	// Check if the service uses a logger
-	if loggerUser, ok := service.(HasLogger); ok {
-		wg.Add(1)
-		loggerUser.BindLogger(r.newLogger(service, r.logger.GetLevel(), r.logOutput))
+	if r.bindLogger(service) {
 		r.events.Emit(events.EventServiceLoggerBound, service).Wait()
-		wg.Done()
 	}
 
-	r.services = append(r.services, service)
+	r.bindClock(service)
 
-	// Check if the service is a HasDependencies
-	if resolver, ok := service.(HasDependencies); ok {
-		// Resolve dependencies before initialization
-		go func() {
-			wg.Add(1)
-			r.resolveDependenciesAndInit(resolver)
-			r.events.Emit(events.EventServiceAdded, service)
-			wg.Done()
-		}()
-	} else {
-		// No dependencies to resolve, directly initialize the service
-		go func() {
-			wg.Add(1)
-			r.initService(service)
-			r.events.Emit(events.EventServiceAdded, service)
-			wg.Done()
-		}()
+	for _, hooks := range r.registryHooks {
+		if hooks.OnAdd != nil {
+			hooks.OnAdd(service)
+		}
 	}
 
+	// the returned wait group is done once the service is initialized and
+	// the handlers of the "service added" event have run
+	wg.Add(1)
+
+	go func() {
+		_ = r.startService(context.Background(), service)
+
+		// services added once the runtime is ready are started right away
+		if err := r.startLate(context.Background(), []IsRuntimeService{service}); err != nil {
+			r.ReportError(service, err, false)
+		}
+
+		r.events.Emit(events.EventServiceAdded, service).Wait()
+		wg.Done()
+	}()
+
 	return &wg
 }
 
-func (r *Runtime) resolveDependenciesAndInit(resolver HasDependencies) {
-	r.events.Emit(events.EventDependencyResolutionStarted, resolver)
+// startService resolves the dependencies of the service, if it has any, and
+// initializes it. It returns the error of the context if it is done before
+// the dependencies are resolved, in which case the service is not
+// initialized.
+func (r *Runtime) startService(ctx context.Context, service IsRuntimeService) error {
+	// the lookups of the service through its view of the runtime are
+	// recorded as its dependencies
+	view := &serviceRuntime{Runtime: r, service: service}
+
+	_, resolves := service.(HasDependencies)
+	_, declares := service.(HasDeclaredDependencies)
+	_, optional := service.(HasOptionalDependencies)
+
+	if resolves || declares || optional {
+		// Resolve dependencies before initialization
+		return r.resolveDependenciesAndInit(ctx, view)
+	}
+
+	// No dependencies to resolve, directly initialize the service
+	r.initService(view)
+
+	return nil
+}
+
+func (r *Runtime) resolveDependenciesAndInit(ctx context.Context, view *serviceRuntime) error {
+	service := view.service
+
+	r.events.Emit(events.EventDependencyResolutionStarted, service)
+	r.setState(service, ServiceStateResolving)
+
+	started := r.clock.Now()
 
 	// Check if all dependencies are resolved
-	for !resolver.DependenciesResolved() {
-		resolver.ResolveDependencies(r)
-		time.Sleep(time.Millisecond * 10)
+	for !r.dependenciesResolved(service, started) {
+		if resolver, ok := service.(HasDependencies); ok && !resolver.DependenciesResolved() {
+			resolver.ResolveDependencies(view)
+		}
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-r.clock.After(time.Millisecond * 10):
+		}
+	}
+
+	// the Lazy handles created while resolving are resolved when used
+	r.servicesMu.RLock()
+	lazies := r.lazies[service]
+	r.servicesMu.RUnlock()
+
+	for _, lazy := range lazies {
+		r.log().Debug().Msgf("not waiting for lazy dependency %s of service %q", lazy, r.ServiceName(service))
 	}
 
-	r.events.Emit(events.EventDependencyResolutionEnded, resolver)
+	r.events.Emit(events.EventDependencyResolutionEnded, service)
 
 	// All dependencies resolved, initialize the service
-	r.initService(resolver)
+	r.initService(view)
+
+	return nil
+}
+
+// dependenciesResolved returns true once the service has resolved its
+// dependencies, the services it declares it depends on are running, and its
+// optional dependencies are running or were given up on.
+func (r *Runtime) dependenciesResolved(service IsRuntimeService, started time.Time) bool {
+	if resolver, ok := service.(HasDependencies); ok && !resolver.DependenciesResolved() {
+		return false
+	}
+
+	if declarer, ok := service.(HasDeclaredDependencies); ok {
+		for _, name := range declarer.DependsOn() {
+			dependency, found := r.Service(name)
+			if !found || r.StateOf(dependency) != ServiceStateRunning {
+				return false
+			}
+		}
+	}
+
+	if optional, ok := service.(HasOptionalDependencies); ok {
+		waiting := r.clock.Now().Sub(started) < r.optionalDependencyTimeout
+
+		for _, name := range optional.OptionalDependsOn() {
+			dependency, found := r.Service(name)
+			if found && r.StateOf(dependency) == ServiceStateRunning {
+				continue
+			}
+
+			if waiting {
+				return false
+			}
+
+			r.log().Debug().Msgf("continuing without optional dependency %q of service %q", name, r.ServiceName(service))
+		}
+	}
+
+	return true
 }
 
 // initService initializes a service and adds it to the Runtime manager.
-func (r *Runtime) initService(service IsRuntimeService) {
+func (r *Runtime) initService(view *serviceRuntime) {
+	service := view.service
+
 	if l, ok := service.(HasLogger); ok && l.Logger() != nil {
 		l.Logger().Debug().Msg("initializing")
 	} else {
-		r.newLogger(service, r.logger.GetLevel(), r.logOutput).Debug().Msgf("initializing")
+		name := r.ServiceName(service)
+		level, dst := r.serviceLogSettings(name)
+		r.newLogger(instanceName(name), level, dst).Debug().Msgf("initializing")
 	}
 
+	r.setState(service, ServiceStateInitializing)
+
+	r.bindTaskGroup(service)
+
 	// Initialize the service
-	service.Init(r)
+	service.Init(view)
+
+	r.setState(service, ServiceStateRunning)
 
 	r.events.Emit(events.EventServiceInitialized, service)
 }
 
 // Services returns a pointer to a slice of interfaces representing the services managed by the Runtime.
 func (r *Runtime) Services() []IsRuntimeService {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
 	duplicate := append([]IsRuntimeService{}, r.services...)
 	return duplicate
 }
 
-// Remove a specific service from the Runtime manager.
+// Remove a specific service from the Runtime manager. Its OnStop hook is
+// called before it is removed, while the services it depends on are still
+// up, then its worker pools are drained and its tasks are cancelled, and its
+// OnStopped hook is called once it is removed.
 func (r *Runtime) Remove(service IsRuntimeService) *sync.WaitGroup {
 	wg := r.events.Emit(events.EventServiceRemoved)
 
+	r.servicesMu.RLock()
+	_, registered := r.names[service]
+	r.servicesMu.RUnlock()
+
+	if registered && service != r {
+		r.stopLate([]IsRuntimeService{service})
+		r.drainPools([]IsRuntimeService{service})
+		r.stopTasks(service)
+	}
+
+	r.servicesMu.Lock()
+
+	removed := false
+
 	for i, svc := range r.services {
 		if svc == service {
-			r.logger.Info().Msgf("removing %q service", service.Name())
 			r.services = append(r.services[:i], r.services[i+1:]...)
+			delete(r.names, service)
+			delete(r.states, service)
+			delete(r.lookups, service)
+			delete(r.lazies, service)
+			removed = true
 			break
 		}
 	}
 
+	r.servicesMu.Unlock()
+
+	if !removed {
+		return wg
+	}
+
+	r.log().Info().Msgf("removing %q service", service.Name())
+	r.unbindEventHandlers(service)
+	r.unbindRequestHandlers(service)
+
+	if child, ok := service.(*Runtime); ok {
+		child.detach(r)
+	}
+
+	for _, hooks := range r.registryHooks {
+		if hooks.OnRemove != nil {
+			hooks.OnRemove(service)
+		}
+	}
+
+	if service != r {
+		r.servicesStopped([]IsRuntimeService{service})
+	}
+
 	return wg
 }
 
-// Shutdown sends an interrupt signal to the Runtime, indicating it should exit.
+// Shutdown gracefully shuts down the services of the Runtime, and ends its
+// run loop. The OnStop hooks of the services are called first, then their
+// worker pools are drained, then they are shut down, and finally their
+// OnStopped hooks are called, each step dependents first.
 func (r *Runtime) Shutdown() *sync.WaitGroup {
 	wg := r.events.Emit(events.EventRuntimeShutdownInitiated)
 
-	for _, service := range r.services {
-		if quitter, ok := service.(HasGracefulShutdown); ok {
+	services := r.hookedServices()
 
-			if l, ok := quitter.(HasLogger); ok && l.Logger() != nil {
-				l.Logger().Info().Msg("shutting down")
-			} else {
-				r.logger.Info().Msgf("shutting down %q service", service.Name())
-			}
+	r.stopServices(services)
 
-			quitter.OnShutdown()
-		}
-	}
+	// the pools requested without an owner may be used by any service
+	r.drainPools([]IsRuntimeService{r})
+	r.shutdownServices(services)
+	r.servicesStopped(services)
+
+	r.log().Info().Msg("exiting")
 
-	r.logger.Info().Msg("exiting")
+	r.stopOnce.Do(func() {
+		close(r.stopped)
+	})
 
 	return wg
 }
 
+// ShutdownContext shuts the runtime down like Shutdown, waiting until it is
+// shut down or the context is done, and returns the errors reported to the
+// runtime, see Err and ExitCode. If the context is done first, the error also
+// wraps ErrShutdownTimeout and the error of the context, and the shutdown
+// goes on in the background.
+func (r *Runtime) ShutdownContext(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		r.Shutdown().Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return r.Err()
+	case <-ctx.Done():
+		return errors.Join(r.Err(), fmt.Errorf("%w: %w", ErrShutdownTimeout, context.Cause(ctx)))
+	}
+}
+
+// shutdownServices calls OnShutdown on each of the services that have it,
+// dependents first, waiting at most for the shutdown timeout of the runtime.
+func (r *Runtime) shutdownServices(services []IsRuntimeService) {
+	var (
+		mu      sync.Mutex
+		current string
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		r.drainPools(services)
+
+		for _, service := range r.dependentsFirst(services) {
+			if quitter, ok := service.(HasGracefulShutdown); ok {
+				mu.Lock()
+				current = service.Name()
+				mu.Unlock()
+
+				if l, ok := quitter.(HasLogger); ok && l.Logger() != nil {
+					l.Logger().Info().Msg("shutting down")
+				} else {
+					r.log().Info().Msgf("shutting down %q service", service.Name())
+				}
+
+				quitter.OnShutdown()
+			}
+
+			r.stopTasks(service)
+			r.setState(service, ServiceStateStopped)
+		}
+	}()
+
+	if r.shutdownTimeout > 0 {
+		select {
+		case <-done:
+		case <-r.clock.After(r.shutdownTimeout):
+			mu.Lock()
+			r.log().Error().Msgf("shutdown timed out after %s, waiting on service %q", r.shutdownTimeout, current)
+			r.recordError(fmt.Errorf("%w after %s, waiting on service %q", ErrShutdownTimeout, r.shutdownTimeout, current))
+			mu.Unlock()
+		}
+	} else {
+		<-done
+	}
+}
+
 // Name returns the name of the Runtime manager.
 func (r *Runtime) Name() string {
 	return r.name
 }
 
-// Run starts the Runtime manager and waits for an interrupt signal to exit.
+// Run starts the Runtime manager and blocks until it is shut down, see
+// RunContext.
+//
+// Deprecated: Run discards the errors of the runtime. Use RunContext, and
+// ExitCode to exit the process with a code that reflects them.
 func (r *Runtime) Run() {
+	_ = r.RunContext(context.Background())
+}
+
+// RunContext starts the Runtime manager and blocks until it is shut down,
+// either by Shutdown, by a fatal error reported with ReportError, by one of
+// the signals it handles, which are only listened for while it runs, or when
+// the context is done. It returns the errors reported to the runtime, see
+// Err and ExitCode.
+//
+// RunContext waits for every service to be initialized, and calls their
+// OnAllInitialized and OnStart hooks. The runtime is then ready: it emits
+// EventRuntimeReady, followed by EventRuntimeRunLoopInitiated. If a service
+// fails to start, or the services are not ready within the startup timeout,
+// the runtime fails with ErrStartFailed and is shut down.
+func (r *Runtime) RunContext(ctx context.Context) error {
+	if len(r.signals) > 0 {
+		signal.Notify(r.quit, r.signals...)
+		defer signal.Stop(r.quit)
+	}
+
+	startCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if r.startupTimeout > 0 {
+		deadline := r.clock.After(r.startupTimeout)
+
+		go func() {
+			select {
+			case <-deadline:
+				cancel(r.startupTimeoutError())
+			case <-startCtx.Done():
+			}
+		}()
+	}
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- r.start(startCtx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-started:
+	case <-startCtx.Done():
+		// a service may not return from OnStart when the deadline elapses
+		err = context.Cause(startCtx)
+	case <-r.quit:
+		cancel(nil)
+		fmt.Printf("\033[2D") // Remove ^C from stdout
+		r.Shutdown().Wait()
+
+		return r.Err()
+	case <-r.failed:
+		cancel(nil)
+		r.Shutdown().Wait()
+
+		return r.Err()
+	case <-r.stopped:
+		return r.Err()
+	}
+
+	// stopped by the caller while starting
+	if ctx.Err() != nil {
+		r.Shutdown().Wait()
+		return r.Err()
+	}
+
+	r.finishStart(err)
+
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrStartFailed, err)
+
+		r.recordError(err)
+		r.log().Error().Err(err).Msg("shutting down")
+		r.Shutdown().Wait()
+
+		return r.Err()
+	}
+
 	r.events.Emit(events.EventRuntimeRunLoopInitiated)
 
-	<-r.quit              // blocks until signal is recieved
-	fmt.Printf("\033[2D") // Remove ^C from stdout
+	select {
+	case <-r.quit: // blocks until signal is recieved
+		fmt.Printf("\033[2D") // Remove ^C from stdout
+		r.Shutdown().Wait()
+	case <-r.failed:
+		r.Shutdown().Wait()
+	case <-ctx.Done():
+		r.Shutdown().Wait()
+	case <-r.stopped:
+	}
 
-	r.Shutdown().Wait()
-	os.Exit(0)
+	return r.Err()
 }
 
 // Events yields the global event bus for the runtime
-func (r *Runtime) Events() *ee.EventEmitter {
+func (r *Runtime) Events() *EventBus {
 	return r.events
 }
 
 func (r *Runtime) bindEventHandlerInterfaces(service IsRuntimeService) {
+	options := make([]SubscribeOption, 0)
+
+	// services that want to learn about past events get them replayed
+	if replayer, ok := service.(HasEventReplay); ok {
+		options = append(options, WithReplay(replayer.ReplayEventsSince()))
+	}
+
+	// the subscriptions are kept, so that they are undone when the service
+	// is removed or replaced
+	subscriptions := make([]*Subscription, 0)
+
+	on := func(event string, fn func(...any)) {
+		subscriptions = append(subscriptions, r.Events().Subscribe(event, fn, options...))
+	}
+
+	defer func() {
+		r.servicesMu.Lock()
+		r.handlers[service] = append(r.handlers[service], subscriptions...)
+		r.servicesMu.Unlock()
+	}()
+
 	if handler, ok := service.(EventHandlerServiceAdded); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventServiceAdded' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventServiceAdded' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventServiceAdded, handler.OnServiceAdded)
+		on(events.EventServiceAdded, handler.OnServiceAdded)
 	}
 
 	if handler, ok := service.(EventHandlerServiceRemoved); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventServiceRemoved' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventServiceRemoved' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventServiceRemoved, handler.OnServiceRemoved)
+		on(events.EventServiceRemoved, handler.OnServiceRemoved)
 	}
 
 	if handler, ok := service.(EventHandlerServiceInitialized); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventServiceInitialized' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventServiceInitialized' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventServiceInitialized, handler.OnServiceInitialized)
+		on(events.EventServiceInitialized, handler.OnServiceInitialized)
 	}
 
 	if handler, ok := service.(EventHandlerServiceEventsBound); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventServiceEventsBound' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventServiceEventsBound' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventServiceEventsBound, handler.OnServiceEventsBound)
+		on(events.EventServiceEventsBound, handler.OnServiceEventsBound)
 	}
 
 	if handler, ok := service.(EventHandlerServiceLoggerBound); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventServiceLoggerBound' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventServiceLoggerBound' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventServiceLoggerBound, handler.OnServiceLoggerBound)
+		on(events.EventServiceLoggerBound, handler.OnServiceLoggerBound)
 	}
 
 	if handler, ok := service.(EventHandlerRuntimeRunLoopInitiated); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventRuntimeRunLoopInitiated' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventRuntimeRunLoopInitiated' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventRuntimeRunLoopInitiated, handler.OnRuntimeRunLoopInitiated)
+		on(events.EventRuntimeRunLoopInitiated, handler.OnRuntimeRunLoopInitiated)
 	}
 
 	if handler, ok := service.(EventHandlerRuntimeShutdownInitiated); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventRuntimeShutdownInitiated' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventRuntimeShutdownInitiated' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventRuntimeShutdownInitiated, handler.OnRuntimeShutdownInitiated)
+		on(events.EventRuntimeShutdownInitiated, handler.OnRuntimeShutdownInitiated)
 	}
 
 	if handler, ok := service.(EventHandlerDependencyResolutionStarted); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventDependencyResolutionStarted' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventDependencyResolutionStarted' event handler for service %q", service.Name())
 		}
-		r.Events().On(events.EventDependencyResolutionStarted, handler.OnDependencyResolutionStarted)
+		on(events.EventDependencyResolutionStarted, handler.OnDependencyResolutionStarted)
 	}
 
 	if handler, ok := service.(EventHandlerDependencyResolutionEnded); ok {
 		if service != r {
-			r.logger.Info().Msgf("bound 'EventDependencyResolutionEnded' event handler for service %q", service.Name())
+			r.log().Info().Msgf("bound 'EventDependencyResolutionEnded' event handler for service %q", service.Name())
+		}
+		on(events.EventDependencyResolutionEnded, handler.OnDependencyResolutionEnded)
+	}
+
+	if handler, ok := service.(EventHandlerServiceReplaced); ok {
+		if service != r {
+			r.log().Info().Msgf("bound 'EventServiceReplaced' event handler for service %q", service.Name())
+		}
+		on(events.EventServiceReplaced, handler.OnServiceReplaced)
+	}
+
+	if handler, ok := service.(EventHandlerServiceError); ok {
+		if service != r {
+			r.log().Info().Msgf("bound 'EventServiceError' event handler for service %q", service.Name())
+		}
+		on(events.EventServiceError, handler.OnServiceError)
+	}
+
+	if handler, ok := service.(EventHandlerRuntimeReady); ok {
+		if service != r {
+			r.log().Info().Msgf("bound 'EventRuntimeReady' event handler for service %q", service.Name())
+		}
+		on(events.EventRuntimeReady, handler.OnRuntimeReady)
+	}
+}
+
+func (r *Runtime) bindRequestHandlers(service IsRuntimeService) {
+	responder, ok := service.(HasRequestHandlers)
+	if !ok {
+		return
+	}
+
+	for name, handler := range responder.RequestHandlers() {
+		if err := r.Events().HandleRequest(name, handler); err != nil {
+			r.log().Error().Err(err).Msgf("binding request handler for service %q", service.Name())
+			continue
 		}
-		r.Events().On(events.EventDependencyResolutionEnded, handler.OnDependencyResolutionEnded)
+
+		r.servicesMu.Lock()
+		r.requests[service] = append(r.requests[service], name)
+		r.servicesMu.Unlock()
+
+		r.log().Debug().Msgf("bound %q request handler for service %q", name, service.Name())
+	}
+}
+
+// unbindEventHandlers undoes the subscriptions of the event handlers of the
+// service.
+func (r *Runtime) unbindEventHandlers(service IsRuntimeService) {
+	r.servicesMu.Lock()
+	subscriptions := r.handlers[service]
+	delete(r.handlers, service)
+	r.servicesMu.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.Unsubscribe()
+	}
+}
+
+// unbindRequestHandlers removes the request handlers that the service
+// registered, leaving those of the same name that other services own.
+func (r *Runtime) unbindRequestHandlers(service IsRuntimeService) {
+	r.servicesMu.Lock()
+	names := r.requests[service]
+	delete(r.requests, service)
+	r.servicesMu.Unlock()
+
+	for _, name := range names {
+		r.Events().RemoveRequestHandler(name)
 	}
 }
 
@@ -271,13 +797,13 @@ func (r *Runtime) OnServiceAdded(args ...any) {
 
 	if service, ok := args[0].(IsRuntimeService); ok {
 		if service != r {
-			r.logger.Info().Msgf("service %q has been added", service.Name())
+			r.log().Info().Msgf("service %q has been added", service.Name())
 		}
 	}
 }
 
 func (r *Runtime) OnRuntimeShutdownInitiated(_ ...any) {
-	r.logger.Warn().Msg("initiating graceful shutdown")
+	r.log().Warn().Msg("initiating graceful shutdown")
 }
 
 func (r *Runtime) OnServiceRemoved(args ...any) {
@@ -286,7 +812,7 @@ func (r *Runtime) OnServiceRemoved(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("removed service %q", service.Name())
+		r.log().Debug().Msgf("removed service %q", service.Name())
 	}
 }
 
@@ -296,7 +822,7 @@ func (r *Runtime) OnServiceInitialized(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("service %q initialized", service.Name())
+		r.log().Debug().Msgf("service %q initialized", service.Name())
 	}
 }
 
@@ -306,7 +832,7 @@ func (r *Runtime) OnServiceEventsBound(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("events bound for service %q", service.Name())
+		r.log().Debug().Msgf("events bound for service %q", service.Name())
 	}
 }
 
@@ -316,12 +842,12 @@ func (r *Runtime) OnServiceLoggerBound(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("logger bound for service %q", service.Name())
+		r.log().Debug().Msgf("logger bound for service %q", service.Name())
 	}
 }
 
 func (r *Runtime) OnRuntimeRunLoopInitiated(_ ...any) {
-	r.logger.Debug().Msg("run loop started")
+	r.log().Debug().Msg("run loop started")
 }
 
 func (r *Runtime) OnDependencyResolutionStarted(args ...any) {
@@ -330,7 +856,7 @@ func (r *Runtime) OnDependencyResolutionStarted(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("dependency resolution started for service %q", service.Name())
+		r.log().Debug().Msgf("dependency resolution started for service %q", service.Name())
 	}
 }
 
@@ -340,6 +866,19 @@ func (r *Runtime) OnDependencyResolutionEnded(args ...any) {
 	}
 
 	if service, ok := args[0].(IsRuntimeService); ok {
-		r.logger.Debug().Msgf("dependency resolution completed for service %q", service.Name())
+		r.log().Debug().Msgf("dependency resolution completed for service %q", service.Name())
+	}
+}
+
+func (r *Runtime) OnServiceReplaced(args ...any) {
+	if len(args) < 2 {
+		return
+	}
+
+	old, okOld := args[0].(IsRuntimeService)
+	replacement, okNew := args[1].(IsRuntimeService)
+
+	if okOld && okNew {
+		r.log().Info().Msgf("service %q has been replaced by %q", old.Name(), replacement.Name())
 	}
 }
@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+)
+
+var _ HasGracefulShutdown = &Runtime{}
+
+// Parent returns the runtime this runtime was added to, or nil if it is a
+// root runtime.
+func (r *Runtime) Parent() *Runtime {
+	r.treeMu.Lock()
+	defer r.treeMu.Unlock()
+
+	return r.parent
+}
+
+// Children returns the runtimes that were added to this runtime as services.
+func (r *Runtime) Children() []*Runtime {
+	children := make([]*Runtime, 0)
+
+	for _, service := range r.Services() {
+		if child, ok := service.(*Runtime); ok && child != r {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+// OnShutdown shuts down a child runtime along with its parent.
+func (r *Runtime) OnShutdown() {
+	r.Shutdown().Wait()
+}
+
+// Restart shuts down the services of the runtime, dependents first, and
+// initializes them again. Their OnStop, OnShutdown and OnStopped hooks are
+// called, and if the runtime is ready, their OnAllInitialized and OnStart
+// hooks once they are all initialized again. Child runtimes restart their
+// own services in turn. The returned wait group is done once every service is
+// initialized, and started, again.
+func (r *Runtime) Restart() *sync.WaitGroup {
+	r.log().Warn().Msg("restarting")
+
+	services := make([]IsRuntimeService, 0)
+	children := make([]*Runtime, 0)
+
+	for _, service := range r.Services() {
+		if child, ok := service.(*Runtime); ok {
+			if child != r {
+				children = append(children, child)
+			}
+
+			continue
+		}
+
+		services = append(services, service)
+	}
+
+	r.stopLate(services)
+	r.shutdownServices(services)
+	r.servicesStopped(services)
+
+	var wg sync.WaitGroup
+
+	for _, child := range children {
+		wg.Add(1)
+
+		go func(child *Runtime) {
+			child.Restart().Wait()
+			wg.Done()
+		}(child)
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		var initialized sync.WaitGroup
+
+		for _, service := range services {
+			initialized.Add(1)
+
+			go func(service IsRuntimeService) {
+				_ = r.startService(context.Background(), service)
+				initialized.Done()
+			}(service)
+		}
+
+		initialized.Wait()
+
+		if err := r.startLate(context.Background(), services); err != nil {
+			r.ReportError(r, err, false)
+		}
+	}()
+
+	return &wg
+}
+
+// attach makes the runtime a child of the parent. It inherits the logging
+// settings of the parent, and forwards its events to the parent as set with
+// WithEventForwarding.
+func (r *Runtime) attach(parent *Runtime) {
+	r.treeMu.Lock()
+
+	if r.parent == parent {
+		r.treeMu.Unlock()
+		return
+	}
+
+	if r.stopForwarding != nil {
+		r.stopForwarding()
+	}
+
+	r.parent = parent
+	r.stopForwarding = r.events.Forward(parent.Events(), "runtime:"+r.name, r.forwardEvents...)
+
+	r.treeMu.Unlock()
+
+	if r.shutdownTimeout == 0 {
+		r.shutdownTimeout = parent.shutdownTimeout
+	}
+
+	r.inheritLogging(parent)
+
+	r.log().Debug().Msgf("attached to runtime %q", parent.Name())
+}
+
+// detach makes the runtime a root runtime again, if it was a child of the
+// given parent.
+func (r *Runtime) detach(parent *Runtime) {
+	r.treeMu.Lock()
+	defer r.treeMu.Unlock()
+
+	if r.parent != parent {
+		return
+	}
+
+	if r.stopForwarding != nil {
+		r.stopForwarding()
+		r.stopForwarding = nil
+	}
+
+	r.parent = nil
+}
+
+// inheritLogging copies the log settings of the parent to the runtime, and
+// rebinds the loggers of the runtime and of its own children. The log
+// overrides of the parent apply to the services of the runtime, unless the
+// runtime has its own override for them.
+func (r *Runtime) inheritLogging(parent *Runtime) {
+	parent.logMu.Lock()
+	level, dst, format, layout := parent.logLevel, parent.logOutput, parent.logFormat, parent.logTimeFormat
+	capture, base := parent.logCapture, parent.baseLogger
+	overrides := append(append([]logOverride{}, parent.inheritedOverrides...), parent.logOverrides...)
+	parent.logMu.Unlock()
+
+	r.logMu.Lock()
+	r.logLevel, r.logOutput, r.logFormat, r.logTimeFormat = level, dst, format, layout
+	r.logCapture, r.baseLogger = capture, base
+	r.inheritedOverrides = overrides
+	r.logMu.Unlock()
+
+	r.rebindLoggers()
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type countingService struct {
+	exampleService
+	name      string
+	inits     atomic.Int32
+	shutdowns atomic.Int32
+}
+
+func (s *countingService) Name() string {
+	return s.name
+}
+
+func (s *countingService) Init(_ IsRuntime) {
+	s.inits.Add(1)
+}
+
+func (s *countingService) OnShutdown() {
+	s.shutdowns.Add(1)
+}
+
+func TestRuntime_Children(t *testing.T) {
+	var buf lockedBuffer
+
+	parent := New("parent", WithoutSignalHandling(), WithLogDestination(&buf), WithLogLevel(zerolog.WarnLevel))
+	child := New("child", WithEventForwarding("child.*"))
+
+	service := &countingService{name: "counted"}
+	child.Add(service).Wait()
+	parent.Add(child).Wait()
+
+	if child.Parent() != parent || len(parent.Children()) != 1 {
+		t.Fatal("expected the child to be attached to the parent")
+	}
+
+	if level := child.ServiceLogLevel("counted"); level != zerolog.WarnLevel {
+		t.Errorf("expected the child to inherit the log level, got %s", level)
+	}
+
+	parent.SetServiceLogLevel("counted", zerolog.ErrorLevel)
+
+	if level := child.ServiceLogLevel("counted"); level != zerolog.ErrorLevel {
+		t.Errorf("expected the child to inherit log overrides, got %s", level)
+	}
+
+	forwarded := make(chan EventRecord, 1)
+	parent.Events().SubscribeRecords("child.ready", func(record EventRecord) {
+		forwarded <- record
+	})
+
+	child.Events().Emit("child.ready")
+	child.Events().Emit("not.forwarded").Wait()
+
+	select {
+	case record := <-forwarded:
+		if record.Origin != "runtime:child" {
+			t.Errorf("unexpected origin %q", record.Origin)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the forwarded event")
+	}
+
+	for _, record := range parent.Events().History() {
+		if record.Name == "not.forwarded" {
+			t.Error("expected only matching events to be forwarded")
+		}
+	}
+
+	child.Restart().Wait()
+
+	if service.shutdowns.Load() != 1 || service.inits.Load() != 2 {
+		t.Errorf("expected the service to restart, got %d shutdowns and %d inits", service.shutdowns.Load(), service.inits.Load())
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		parent.Run()
+		close(done)
+	}()
+
+	parent.Shutdown().Wait()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected Run to return after Shutdown")
+	}
+
+	if service.shutdowns.Load() != 2 {
+		t.Error("expected the parent to shut down the child")
+	}
+
+	if strings.Contains(buf.String(), "INF") {
+		t.Errorf("expected no info output, got %q", buf.String())
+	}
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// DefaultOptionalDependencyTimeout is how long services wait for their
+// optional dependencies, unless set with WithOptionalDependencyTimeout.
+const DefaultOptionalDependencyTimeout = time.Second
+
+// ErrDependencyNotReady is returned by a Lazy dependency whose service is not
+// running yet.
+var ErrDependencyNotReady = errors.New("dependency not ready")
+
+// Lazy is a handle to a dependency that is resolved when it is used, rather
+// than before the dependent service is initialized. This lets services
+// depend on each other without holding back their initialization, eg when
+// the dependency is only needed to serve requests.
+//
+// The dependency is looked up on every use, so a Lazy follows services that
+// are replaced. A Lazy created with the runtime given to the
+// ResolveDependencies or Init of a service is recorded by the runtime as a
+// lazy dependency of the service: the resolution does not wait for it, but it is part of the
+// dependency graph, which orders the start and stop hooks of the services.
+type Lazy[T any] struct {
+	rt   IsRuntime
+	name string
+}
+
+// NewLazy creates a handle to the service with the given name, or to the
+// first service of type T if the name is empty.
+func NewLazy[T any](rt IsRuntime, name string) *Lazy[T] {
+	// the dependency is looked up through the runtime itself, so that
+	// using the handle is not recorded as a lookup
+	l := &Lazy[T]{rt: unwrapRuntime(rt), name: name}
+
+	if recorder, ok := rt.(dependencyRecorder); ok {
+		recorder.recordLazy(l)
+	}
+
+	return l
+}
+
+// TryGet returns the dependency if it is running, and ErrDependencyNotReady
+// otherwise.
+func (l *Lazy[T]) TryGet() (T, error) {
+	var zero T
+
+	service, found := l.lookup()
+	if !found || l.rt.StateOf(service) != ServiceStateRunning {
+		return zero, fmt.Errorf("%w: %s", ErrDependencyNotReady, l)
+	}
+
+	dependency, ok := service.(T)
+	if !ok {
+		return zero, fmt.Errorf("service %q is a %T, not a %T", l.name, service, zero)
+	}
+
+	return dependency, nil
+}
+
+// Get returns the dependency, waiting for it to be running until the context
+// is done.
+func (l *Lazy[T]) Get(ctx context.Context) (T, error) {
+	if dependency, err := l.TryGet(); !errors.Is(err, ErrDependencyNotReady) {
+		return dependency, err
+	}
+
+	initialized := make(chan struct{}, 1)
+
+	subscription := l.rt.Events().Subscribe(events.EventServiceInitialized, func(...any) {
+		select {
+		case initialized <- struct{}{}:
+		default:
+		}
+	})
+
+	defer subscription.Unsubscribe()
+
+	for {
+		// checked again once subscribed, so that no initialization is missed
+		dependency, err := l.TryGet()
+		if !errors.Is(err, ErrDependencyNotReady) {
+			return dependency, err
+		}
+
+		select {
+		case <-initialized:
+		case <-ctx.Done():
+			return dependency, fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+	}
+}
+
+// String describes the dependency.
+func (l *Lazy[T]) String() string {
+	if l.name != "" {
+		return fmt.Sprintf("%q", l.name)
+	}
+
+	var zero T
+
+	return fmt.Sprintf("%T", &zero)[1:]
+}
+
+func (l *Lazy[T]) lookup() (IsRuntimeService, bool) {
+	if l.name != "" {
+		return l.rt.Service(l.name)
+	}
+
+	for _, service := range l.rt.Services() {
+		if _, ok := service.(T); ok {
+			return service, true
+		}
+	}
+
+	return nil, false
+}
+
+// resolves returns true if the service, registered under the name, is the
+// dependency of the handle.
+func (l *Lazy[T]) resolves(name string, service IsRuntimeService) bool {
+	if l.name != "" {
+		return name == l.name
+	}
+
+	_, ok := service.(T)
+
+	return ok
+}
+
+// lazyDependency is a Lazy handle, whatever the type of its dependency.
+type lazyDependency interface {
+	fmt.Stringer
+	resolves(name string, service IsRuntimeService) bool
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type optionalService struct {
+	countingService
+	optional []string
+	running  []string
+}
+
+func (s *optionalService) OptionalDependsOn() []string {
+	return s.optional
+}
+
+func (s *optionalService) Init(rt IsRuntime) {
+	s.countingService.Init(rt)
+
+	// the optional dependencies that were running when initialized
+	for _, name := range s.optional {
+		if dependency, found := rt.Service(name); found && rt.StateOf(dependency) == ServiceStateRunning {
+			s.running = append(s.running, name)
+		}
+	}
+}
+
+type lazyService struct {
+	countingService
+	db *Lazy[*countingService]
+}
+
+func (s *lazyService) Init(rt IsRuntime) {
+	s.countingService.Init(rt)
+	s.db = NewLazy[*countingService](rt, "db")
+}
+
+func TestRuntime_OptionalDependencies(t *testing.T) {
+	rt := New("optional", WithoutSignalHandling(), WithOptionalDependencyTimeout(time.Millisecond*200))
+
+	present := &optionalService{countingService: countingService{name: "present"}, optional: []string{"metrics"}}
+	missing := &optionalService{countingService: countingService{name: "missing"}, optional: []string{"nothing"}}
+
+	rt.Add(present)
+	rt.Add(missing)
+	rt.Add(&countingService{name: "metrics"}).Wait()
+
+	done := make(chan struct{})
+
+	go func() {
+		for rt.StateOf(present) != ServiceStateRunning || rt.StateOf(missing) != ServiceStateRunning {
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected services to be initialized without their missing optional dependencies")
+	}
+
+	if len(present.running) != 1 || present.running[0] != "metrics" {
+		t.Errorf("expected present to wait for metrics, got %v", present.running)
+	}
+
+	if len(missing.running) != 0 {
+		t.Errorf("unexpected optional dependencies of missing %v", missing.running)
+	}
+
+	for _, edge := range rt.DependencyGraph().Edges {
+		if !edge.Optional {
+			t.Errorf("expected only optional edges, got %+v", edge)
+		}
+	}
+}
+
+func TestLazy(t *testing.T) {
+	rt := New("lazy", WithoutSignalHandling())
+
+	byName := NewLazy[*countingService](rt, "db")
+	byType := NewLazy[*countingService](rt, "")
+
+	if _, err := byName.TryGet(); !errors.Is(err, ErrDependencyNotReady) {
+		t.Errorf("expected ErrDependencyNotReady, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if _, err := byType.Get(ctx); !errors.Is(err, ErrDependencyNotReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Get to time out with ErrDependencyNotReady, got %v", err)
+	}
+
+	// the api does not wait for its lazy dependency to be initialized
+	api := &lazyService{countingService: countingService{name: "api"}}
+	rt.Add(api).Wait()
+
+	if rt.StateOf(api) != ServiceStateRunning {
+		t.Fatalf("expected the api to be running, got %s", rt.StateOf(api))
+	}
+
+	db := &countingService{name: "db"}
+
+	got := make(chan *countingService, 1)
+
+	go func() {
+		dependency, err := byName.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+
+		got <- dependency
+	}()
+
+	rt.Add(db).Wait()
+
+	select {
+	case dependency := <-got:
+		if dependency != db {
+			t.Errorf("unexpected dependency %v", dependency)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the lazy dependency")
+	}
+
+	if dependency, err := byType.TryGet(); err != nil || dependency != db {
+		t.Errorf("expected the dependency by type, got %v: %v", dependency, err)
+	}
+
+	expected := DependencyEdge{From: "api", To: "db", Lazy: true}
+
+	if edges := rt.DependencyGraph().Edges; len(edges) != 1 || edges[0] != expected {
+		t.Errorf("expected edge %+v, got %+v", expected, edges)
+	}
+
+	if order := rt.dependenciesFirst(rt.hookedServices()); order[0] != db {
+		t.Errorf("expected the lazy dependency of the api to be started first, got %v", order)
+	}
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// the exit codes yielded by ExitCode, so that supervisors can tell a clean
+// shutdown from the different kinds of failures
+const (
+	// ExitCodeOK is the exit code of a runtime that shut down cleanly.
+	ExitCodeOK = 0
+
+	// ExitCodeError is the exit code of a runtime to which services
+	// reported errors.
+	ExitCodeError = 1
+
+	// ExitCodeFatal is the exit code of a runtime that was shut down by a
+	// fatal error.
+	ExitCodeFatal = 2
+
+	// ExitCodeStartFailed is the exit code of a runtime whose services
+	// failed to start, or were not ready within the startup timeout.
+	ExitCodeStartFailed = 3
+
+	// ExitCodeShutdownTimeout is the exit code of a runtime whose services
+	// did not shut down within the shutdown timeout.
+	ExitCodeShutdownTimeout = 4
+)
+
+var (
+	// ErrStartFailed is the error of a runtime whose services failed to
+	// start.
+	ErrStartFailed = errors.New("start failed")
+
+	// ErrShutdownTimeout is the error of a runtime whose services did not
+	// shut down within the shutdown timeout, see WithShutdownTimeout.
+	ErrShutdownTimeout = errors.New("shutdown timed out")
+)
+
+// ServiceError is an error reported by, or on behalf of, a service.
+type ServiceError struct {
+	// Service is the name of the service.
+	Service string
+
+	// Err is the error.
+	Err error
+
+	// Fatal is true for errors that shut the runtime down.
+	Fatal bool
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("service %q: %v", e.Service, e.Err)
+}
+
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// ReportError reports an error of a service to the runtime. The error is
+// logged, emitted as EventServiceError, and included in Err. A fatal error
+// also ends the run loop, which shuts the runtime down.
+//
+// A child runtime reports the errors of its services to its parent as well.
+func (r *Runtime) ReportError(service IsRuntimeService, err error, fatal bool) {
+	if err == nil {
+		return
+	}
+
+	name := r.ServiceName(service)
+	if name == "" && service != nil {
+		name = service.Name()
+	}
+
+	serviceErr := &ServiceError{Service: name, Err: err, Fatal: fatal}
+
+	r.recordError(serviceErr)
+
+	if fatal {
+		r.log().Error().Err(err).Msgf("service %q failed, shutting down", name)
+
+		r.failOnce.Do(func() {
+			close(r.failed)
+		})
+	} else {
+		r.log().Error().Err(err).Msgf("service %q failed", name)
+	}
+
+	r.events.Emit(events.EventServiceError, service, err, fatal)
+
+	if parent := r.Parent(); parent != nil {
+		parent.ReportError(r, serviceErr, fatal)
+	}
+}
+
+// Err returns the errors reported to the runtime so far, joined, or nil if
+// there are none.
+func (r *Runtime) Err() error {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+
+	return errors.Join(r.errs...)
+}
+
+// ExitCode returns the exit code for the errors reported to the runtime so
+// far, see ExitCode.
+func (r *Runtime) ExitCode() int {
+	return ExitCode(r.Err())
+}
+
+func (r *Runtime) recordError(err error) {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+
+	r.errs = append(r.errs, err)
+}
+
+// ExitCode maps the error of a runtime, as returned by RunContext or Err, to
+// a process exit code. Failures to start take precedence over fatal errors,
+// which take precedence over shutdown timeouts, which take precedence over
+// other errors.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case errors.Is(err, ErrStartFailed), errors.Is(err, ErrStartupTimeout):
+		return ExitCodeStartFailed
+	case isFatal(err):
+		return ExitCodeFatal
+	case errors.Is(err, ErrShutdownTimeout):
+		return ExitCodeShutdownTimeout
+	default:
+		return ExitCodeError
+	}
+}
+
+// isFatal returns true if the error is, or wraps, a fatal ServiceError.
+func isFatal(err error) bool {
+	switch e := err.(type) {
+	case *ServiceError:
+		return e.Fatal || isFatal(e.Err)
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range e.Unwrap() {
+			if isFatal(wrapped) {
+				return true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return isFatal(e.Unwrap())
+	}
+
+	return false
+}
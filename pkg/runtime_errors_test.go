@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// failingService reports an error once it is initialized.
+type failingService struct {
+	countingService
+	err   error
+	fatal bool
+}
+
+func (s *failingService) Init(rt IsRuntime) {
+	rt.ReportError(s, s.err, s.fatal)
+}
+
+func TestRuntime_ReportError(t *testing.T) {
+	rt := New("errors", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	failing := &failingService{countingService: countingService{name: "failing"}, err: errors.New("cache unavailable")}
+	rt.Add(failing).Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		_ = rt.WaitReady(ctx)
+		cancel()
+	}()
+
+	err := rt.RunContext(ctx)
+
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Service != "failing" || serviceErr.Fatal {
+		t.Fatalf("expected the non fatal error of the service, got %v", err)
+	}
+
+	if code := ExitCode(err); code != ExitCodeError {
+		t.Errorf("expected exit code %d, got %d", ExitCodeError, code)
+	}
+
+	found := false
+
+	for _, record := range rt.Events().History() {
+		if record.Name == events.EventServiceError && record.Args[0] == failing {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("expected the error to be emitted")
+	}
+}
+
+func TestRuntime_ReportFatalError(t *testing.T) {
+	parent := New("parent", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+	child := New("child")
+
+	parent.Add(child).Wait()
+
+	// shuts down the parent, which is run, through the child
+	child.Add(&failingService{countingService: countingService{name: "failing"}, err: errors.New("corrupt state"), fatal: true})
+
+	done := make(chan error)
+
+	go func() {
+		done <- parent.RunContext(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if code := ExitCode(err); code != ExitCodeFatal {
+			t.Errorf("expected exit code %d, got %d: %v", ExitCodeFatal, code, err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the fatal error to shut the runtime down")
+	}
+
+	if ExitCode(child.Err()) != ExitCodeFatal {
+		t.Error("expected the child to keep the error as well")
+	}
+}
+
+// blockingService does not shut down until it is released.
+type blockingService struct {
+	countingService
+	release chan struct{}
+}
+
+func (s *blockingService) OnShutdown() {
+	<-s.release
+}
+
+func TestRuntime_ShutdownContext(t *testing.T) {
+	rt := New("errors", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	rt.Add(&failingService{countingService: countingService{name: "failing"}, err: errors.New("cache unavailable")}).Wait()
+
+	err := rt.ShutdownContext(context.Background())
+
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Service != "failing" || ExitCode(err) != ExitCodeError {
+		t.Errorf("expected the error of the service, got %v", err)
+	}
+
+	// the shutdown is abandoned when the context is done
+	rt = New("errors", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	blocking := &blockingService{countingService: countingService{name: "blocking"}, release: make(chan struct{})}
+	rt.Add(blocking).Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	err = rt.ShutdownContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || ExitCode(err) != ExitCodeShutdownTimeout {
+		t.Errorf("expected the shutdown to time out, got %v", err)
+	}
+
+	close(blocking.release)
+
+	if err := rt.WaitReady(context.Background()); !errors.Is(err, ErrRuntimeStopped) {
+		t.Errorf("expected the shutdown to go on in the background, got %v", err)
+	}
+}
+
+func TestRuntime_StartFailureExitCode(t *testing.T) {
+	rt := New("errors", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	rt.Add(&hookedService{log: &lifecycleLog{}, startErr: errors.New("port in use"), declaringService: declaringService{countingService: countingService{name: "server"}}})
+
+	err := rt.RunContext(context.Background())
+	if !errors.Is(err, ErrStartFailed) || ExitCode(err) != ExitCodeStartFailed {
+		t.Errorf("expected the start to fail, got %v", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	fatal := &ServiceError{Service: "a", Err: errors.New("boom"), Fatal: true}
+	failed := &ServiceError{Service: "b", Err: errors.New("oops")}
+	timeout := fmt.Errorf("%w after 1s", ErrShutdownTimeout)
+	start := fmt.Errorf("%w: %w", ErrStartFailed, failed)
+
+	for _, test := range []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitCodeOK},
+		{failed, ExitCodeError},
+		{errors.Join(failed, timeout), ExitCodeShutdownTimeout},
+		{errors.Join(timeout, fatal), ExitCodeFatal},
+		{&ServiceError{Service: "child", Err: fatal}, ExitCodeFatal},
+		{errors.Join(fatal, start), ExitCodeStartFailed},
+		{fmt.Errorf("%w after 1s", ErrStartupTimeout), ExitCodeStartFailed},
+	} {
+		if code := ExitCode(test.err); code != test.code {
+			t.Errorf("expected exit code %d for %v, got %d", test.code, test.err, code)
+		}
+	}
+}
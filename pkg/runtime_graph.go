@@ -0,0 +1,334 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is a snapshot of the services of a runtime and of how they
+// depend on each other, see Runtime.DependencyGraph.
+type DependencyGraph struct {
+	Runtime string           `json:"runtime"`
+	Nodes   []DependencyNode `json:"nodes"`
+	Edges   []DependencyEdge `json:"edges"`
+}
+
+// DependencyNode is a service in a DependencyGraph.
+type DependencyNode struct {
+	// Name is the name the service was added under.
+	Name string `json:"name"`
+
+	// Type is the Go type of the service.
+	Type string `json:"type"`
+
+	// State is the state of the service when the graph was made.
+	State ServiceState `json:"state"`
+
+	// Metadata is the metadata of the service, if it implements
+	// HasMetadata.
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// DependencyEdge is a dependency of one service upon another.
+type DependencyEdge struct {
+	// From is the name of the dependent service.
+	From string `json:"from"`
+
+	// To is the name of the service it depends on.
+	To string `json:"to"`
+
+	// Declared is true for dependencies declared with
+	// HasDeclaredDependencies, and false for dependencies observed by the
+	// runtime: the services that the service looked up through the runtime
+	// given to its ResolveDependencies or Init.
+	Declared bool `json:"declared"`
+
+	// Optional is true for dependencies declared with
+	// HasOptionalDependencies.
+	Optional bool `json:"optional,omitempty"`
+
+	// Lazy is true for dependencies upon the service of a Lazy handle,
+	// created with the runtime given to the ResolveDependencies or Init of
+	// the service.
+	Lazy bool `json:"lazy,omitempty"`
+}
+
+// DependencyGraph returns the services of the runtime and their dependencies.
+//
+// Dependencies are either declared by services that implement
+// HasDeclaredDependencies, lazy, see Lazy, or observed: a service that looks
+// up another one with Service, ServiceOfType or ServicesOfType, through the
+// runtime given to its ResolveDependencies or Init, depends on it.
+func (r *Runtime) DependencyGraph() DependencyGraph {
+	graph := DependencyGraph{
+		Runtime: r.name,
+		Nodes:   make([]DependencyNode, 0),
+		Edges:   make([]DependencyEdge, 0),
+	}
+
+	services := make([]IsRuntimeService, 0)
+
+	for _, service := range r.Services() {
+		if service != r {
+			services = append(services, service)
+		}
+	}
+
+	registered := make(map[string]bool)
+
+	for _, service := range services {
+		name := r.ServiceName(service)
+
+		node := DependencyNode{
+			Name:  name,
+			Type:  fmt.Sprintf("%T", service),
+			State: r.StateOf(service),
+		}
+
+		if metadata, ok := ServiceMetadata(service); ok {
+			node.Metadata = &metadata
+		}
+
+		graph.Nodes = append(graph.Nodes, node)
+		registered[name] = true
+	}
+
+	for _, service := range services {
+		from := r.ServiceName(service)
+		edges := make(map[string]DependencyEdge)
+
+		for _, to := range r.lazyDependencies(service) {
+			if to != from {
+				edges[to] = DependencyEdge{From: from, To: to, Lazy: true}
+			}
+		}
+
+		for _, to := range r.observedDependencies(service) {
+			// the looked up service may have been removed since
+			if to != from && registered[to] {
+				edges[to] = DependencyEdge{From: from, To: to}
+			}
+		}
+
+		if optional, ok := service.(HasOptionalDependencies); ok {
+			for _, to := range optional.OptionalDependsOn() {
+				edges[to] = DependencyEdge{From: from, To: to, Declared: true, Optional: true}
+			}
+		}
+
+		if declarer, ok := service.(HasDeclaredDependencies); ok {
+			for _, to := range declarer.DependsOn() {
+				edges[to] = DependencyEdge{From: from, To: to, Declared: true}
+			}
+		}
+
+		for _, edge := range edges {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph
+}
+
+// JSON encodes the graph as indented JSON.
+func (g DependencyGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DOT encodes the graph in the Graphviz DOT language. Observed dependencies
+// are dashed, optional dependencies are dotted, and lazy dependencies are
+// dashed with a hollow arrowhead.
+func (g DependencyGraph) DOT() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "digraph %q {\n", g.Runtime)
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&sb, "\t%q [label=%q];\n", node.Name, node.Name+"\n"+node.State.String())
+	}
+
+	for _, edge := range g.Edges {
+		switch {
+		case edge.Optional:
+			fmt.Fprintf(&sb, "\t%q -> %q [style=dotted];\n", edge.From, edge.To)
+		case edge.Lazy:
+			fmt.Fprintf(&sb, "\t%q -> %q [style=dashed, arrowhead=empty];\n", edge.From, edge.To)
+		case edge.Declared:
+			fmt.Fprintf(&sb, "\t%q -> %q;\n", edge.From, edge.To)
+		default:
+			fmt.Fprintf(&sb, "\t%q -> %q [style=dashed];\n", edge.From, edge.To)
+		}
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// Mermaid encodes the graph as a Mermaid flowchart. Observed dependencies
+// are dotted, and optional and lazy dependencies are labelled as such.
+func (g DependencyGraph) Mermaid() string {
+	var sb strings.Builder
+
+	sb.WriteString("graph TD\n")
+
+	// mermaid identifiers cannot contain most characters of service
+	// names, so nodes are numbered and labelled with their name
+	ids := make(map[string]string)
+
+	for i, node := range g.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+		label := strings.ReplaceAll(node.Name, `"`, "#quot;")
+		fmt.Fprintf(&sb, "\t%s[\"%s (%s)\"]\n", ids[node.Name], label, node.State)
+	}
+
+	for _, edge := range g.Edges {
+		from, to := ids[edge.From], ids[edge.To]
+		if from == "" || to == "" {
+			continue // declared dependency upon a missing service
+		}
+
+		switch {
+		case edge.Optional:
+			fmt.Fprintf(&sb, "\t%s -. optional .-> %s\n", from, to)
+		case edge.Lazy:
+			fmt.Fprintf(&sb, "\t%s -. lazy .-> %s\n", from, to)
+		case edge.Declared:
+			fmt.Fprintf(&sb, "\t%s --> %s\n", from, to)
+		default:
+			fmt.Fprintf(&sb, "\t%s -.-> %s\n", from, to)
+		}
+	}
+
+	return sb.String()
+}
+
+// dependencyRecorder is implemented by the views of the runtime given to
+// services, which record the services looked up through them, and the Lazy
+// handles created with them, see serviceRuntime.
+type dependencyRecorder interface {
+	recordLookup(dependency IsRuntimeService)
+	recordLazy(dependency lazyDependency)
+}
+
+// recordLookup records that a service was looked up through the registry of
+// the runtime, if the runtime records lookups.
+func recordLookup(rt IsRuntime, dependency IsRuntimeService) {
+	if recorder, ok := rt.(dependencyRecorder); ok {
+		recorder.recordLookup(dependency)
+	}
+}
+
+// serviceRuntime is the view of the runtime given to a service to resolve
+// its dependencies and to be initialized. The services it looks up through
+// the view, and the Lazy handles it creates with it, are recorded as its
+// dependencies.
+type serviceRuntime struct {
+	*Runtime
+	service IsRuntimeService
+}
+
+// Service looks up a service by the name it was added under, and records it
+// as a dependency.
+func (v *serviceRuntime) Service(name string) (IsRuntimeService, bool) {
+	dependency, found := v.Runtime.Service(name)
+	if found {
+		v.recordLookup(dependency)
+	}
+
+	return dependency, found
+}
+
+func (v *serviceRuntime) recordLookup(dependency IsRuntimeService) {
+	v.Runtime.recordLookup(v.service, dependency)
+}
+
+func (v *serviceRuntime) recordLazy(dependency lazyDependency) {
+	v.Runtime.recordLazy(v.service, dependency)
+}
+
+// unwrapRuntime returns the runtime behind a view given to a service.
+func unwrapRuntime(rt IsRuntime) IsRuntime {
+	if view, ok := rt.(*serviceRuntime); ok {
+		return view.Runtime
+	}
+
+	return rt
+}
+
+// recordLookup records a dependency of a service upon the service it looked
+// up, by name, so that the dependency holds when the service it names is
+// replaced.
+func (r *Runtime) recordLookup(dependent, dependency IsRuntimeService) {
+	if dependent == dependency {
+		return
+	}
+
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	name, found := r.names[dependency]
+	if _, registered := r.names[dependent]; !found || !registered {
+		return
+	}
+
+	if r.lookups[dependent] == nil {
+		r.lookups[dependent] = make(map[string]bool)
+	}
+
+	r.lookups[dependent][name] = true
+}
+
+// recordLazy records a lazy dependency of a service.
+func (r *Runtime) recordLazy(dependent IsRuntimeService, dependency lazyDependency) {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	if _, registered := r.names[dependent]; registered {
+		r.lazies[dependent] = append(r.lazies[dependent], dependency)
+	}
+}
+
+// lazyDependencies returns the names of the services that the Lazy handles
+// of the service resolve to, for those that are registered.
+func (r *Runtime) lazyDependencies(service IsRuntimeService) []string {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	names := make([]string, 0, len(r.lazies[service]))
+
+	for _, lazy := range r.lazies[service] {
+		for _, candidate := range r.services {
+			if lazy.resolves(r.names[candidate], candidate) {
+				names = append(names, r.names[candidate])
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+// observedDependencies returns the names of the services that the service
+// looked up.
+func (r *Runtime) observedDependencies(service IsRuntimeService) []string {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	names := make([]string, 0, len(r.lookups[service]))
+	for name := range r.lookups[service] {
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type declaringService struct {
+	countingService
+	dependsOn []string
+}
+
+func (s *declaringService) DependsOn() []string {
+	return s.dependsOn
+}
+
+func TestRuntime_DependencyGraph(t *testing.T) {
+	rt := New("graph", WithoutSignalHandling())
+
+	dependent := &dependentService{replaced: make(chan IsRuntimeService, 1)}
+	api := &declaringService{countingService: countingService{name: "api"}, dependsOn: []string{"counted"}}
+
+	// the declared dependency holds back the api until it is running
+	added := rt.Add(api)
+
+	if state := rt.StateOf(api); state == ServiceStateRunning {
+		t.Fatalf("expected the api to wait for its dependency, got %s", state)
+	}
+
+	rt.Add(dependent)
+	rt.Add(&countingService{name: "counted"}).Wait()
+	added.Wait()
+
+	// the dependencies are resolved in the background
+	for rt.StateOf(api) != ServiceStateRunning || rt.StateOf(dependent) != ServiceStateRunning {
+		time.Sleep(time.Millisecond)
+	}
+
+	graph := rt.DependencyGraph()
+
+	if len(graph.Nodes) != 3 {
+		t.Fatalf("expected 3 services, got %+v", graph.Nodes)
+	}
+
+	for _, node := range graph.Nodes {
+		if node.State != ServiceStateRunning {
+			t.Errorf("expected %q to be running, got %s", node.Name, node.State)
+		}
+	}
+
+	expected := []DependencyEdge{
+		{From: "api", To: "counted", Declared: true},
+		{From: "dependent", To: "counted", Declared: false},
+	}
+
+	if len(graph.Edges) != len(expected) {
+		t.Fatalf("expected edges %+v, got %+v", expected, graph.Edges)
+	}
+
+	for i := range expected {
+		if graph.Edges[i] != expected[i] {
+			t.Errorf("expected edge %+v, got %+v", expected[i], graph.Edges[i])
+		}
+	}
+
+	if dot := graph.DOT(); !strings.Contains(dot, `"dependent" -> "counted" [style=dashed];`) {
+		t.Errorf("unexpected DOT output %s", dot)
+	}
+
+	if mermaid := graph.Mermaid(); !strings.Contains(mermaid, "n0 --> n2") {
+		t.Errorf("unexpected Mermaid output %s", mermaid)
+	}
+
+	data, err := graph.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]any
+	if err = json.Unmarshal(data, &decoded); err != nil || !strings.Contains(string(data), `"state": "running"`) {
+		t.Errorf("unexpected JSON output %s: %v", data, err)
+	}
+}
+
+// asyncResolver looks its dependency up from another goroutine.
+type asyncResolver struct {
+	countingService
+	resolved atomic.Bool
+}
+
+func (s *asyncResolver) DependenciesResolved() bool {
+	return s.resolved.Load()
+}
+
+func (s *asyncResolver) ResolveDependencies(rt IsRuntime) {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if _, found := rt.Service("counted"); found {
+			s.resolved.Store(true)
+		}
+	}()
+
+	<-done
+}
+
+func TestRuntime_DependencyGraphAsyncLookup(t *testing.T) {
+	rt := New("graph", WithoutSignalHandling())
+
+	resolver := &asyncResolver{countingService: countingService{name: "resolver"}}
+
+	added := rt.Add(resolver)
+	rt.Add(&countingService{name: "counted"}).Wait()
+	added.Wait()
+
+	expected := DependencyEdge{From: "resolver", To: "counted"}
+
+	if edges := rt.DependencyGraph().Edges; len(edges) != 1 || edges[0] != expected {
+		t.Errorf("expected edge %+v, got %+v", expected, edges)
+	}
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// HasAllInitializedHook is an optional interface for services that act once
+// every service of the runtime is initialized.
+//
+// When the runtime is run, it waits for every service to be initialized, then
+// calls OnAllInitialized on the services that have it, dependencies first,
+// before any OnStart hook. Services initialized once the runtime is ready,
+// such as services added later, get the hook as soon as they are initialized.
+type HasAllInitializedHook interface {
+	IsRuntimeService
+
+	// OnAllInitialized is called once every service is initialized.
+	OnAllInitialized()
+}
+
+// HasStartHook is an optional interface for services that start working once
+// every service of the runtime is initialized, such as servers that accept
+// connections.
+//
+// OnStart is called after every OnAllInitialized hook, dependencies first,
+// so that the dependencies of a service are started before it is. The run
+// loop begins once every OnStart hook has returned. If one of them returns an
+// error, the services that follow are not started, and the runtime is shut
+// down. Services initialized once the runtime is ready are started as soon
+// as they are initialized, and the error of their OnStart hook is reported
+// instead, see Runtime.ReportError, or returned by ReplaceContext.
+type HasStartHook interface {
+	IsRuntimeService
+
+	// OnStart starts the service. The context is cancelled when the runtime
+	// is shut down while starting, or when the startup timeout elapses.
+	OnStart(ctx context.Context) error
+}
+
+// HasStopHook is an optional interface for services that stop working before
+// the runtime is shut down, while the services they depend on are still up.
+//
+// When the runtime shuts down, OnStop is called before any worker pool is
+// drained or OnShutdown is called, dependents first. Errors are logged, and do
+// not prevent the shutdown. OnStop is also called when the service is
+// removed, replaced or restarted.
+type HasStopHook interface {
+	IsRuntimeService
+
+	// OnStop stops the service. The context is cancelled after the shutdown
+	// timeout of the runtime, if it has one.
+	OnStop(ctx context.Context) error
+}
+
+// HasStoppedHook is an optional interface for services that act once every
+// service of the runtime is shut down, such as flushing telemetry.
+//
+// OnStopped is called after every OnShutdown, dependents first, and when the
+// service is removed, replaced or restarted.
+type HasStoppedHook interface {
+	IsRuntimeService
+
+	// OnStopped is called once every service is shut down.
+	OnStopped()
+}
+
+// OnStart runs the start phases of a child runtime when its parent runs them,
+// see HasStartHook.
+func (r *Runtime) OnStart(ctx context.Context) error {
+	err := r.start(ctx)
+	r.finishStart(err)
+
+	return err
+}
+
+// start waits for every service to be initialized, then calls the
+// OnAllInitialized hooks and the OnStart hooks of the services, dependencies
+// first. It returns the error of the first OnStart hook that fails.
+func (r *Runtime) start(ctx context.Context) error {
+	if err := r.waitInitialized(ctx); err != nil {
+		return context.Cause(ctx)
+	}
+
+	return r.startServices(ctx, r.hookedServices())
+}
+
+// startLate calls the OnAllInitialized and OnStart hooks of services that were
+// initialized after the runtime was ready, such as services that were added,
+// replacements and restarted services, so that they are started like the
+// services that were there when it was run. It does nothing if the runtime is
+// not ready, in which case the services are started when it is run.
+func (r *Runtime) startLate(ctx context.Context, services []IsRuntimeService) error {
+	if !r.Ready() {
+		return nil
+	}
+
+	return r.startServices(ctx, services)
+}
+
+// startServices calls the OnAllInitialized hooks and the OnStart hooks of the
+// services, dependencies first. It returns the error of the first OnStart
+// hook that fails.
+func (r *Runtime) startServices(ctx context.Context, services []IsRuntimeService) error {
+	services = r.dependenciesFirst(services)
+
+	for _, service := range services {
+		if hook, ok := service.(HasAllInitializedHook); ok {
+			hook.OnAllInitialized()
+		}
+	}
+
+	for _, service := range services {
+		hook, ok := service.(HasStartHook)
+		if !ok {
+			continue
+		}
+
+		r.log().Debug().Msgf("starting %q service", r.ServiceName(service))
+
+		if err := hook.OnStart(ctx); err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+
+			return fmt.Errorf("starting service %q: %w", r.ServiceName(service), err)
+		}
+
+		r.markStarted(service)
+	}
+
+	return nil
+}
+
+// waitInitialized waits until every service is initialized, or the context
+// is done.
+func (r *Runtime) waitInitialized(ctx context.Context) error {
+	initialized := make(chan struct{}, 1)
+
+	subscription := r.events.Subscribe(events.EventServiceInitialized, func(...any) {
+		select {
+		case initialized <- struct{}{}:
+		default:
+		}
+	})
+
+	defer subscription.Unsubscribe()
+
+	for {
+		// checked again once subscribed, so that no initialization is missed
+		if r.allInitialized() {
+			return nil
+		}
+
+		select {
+		case <-initialized:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Runtime) allInitialized() bool {
+	for _, service := range r.Services() {
+		switch r.StateOf(service) {
+		case ServiceStateRunning, ServiceStateStopped:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// stopServices calls the OnStop hooks of the services, dependents first,
+// with a context cancelled after the shutdown timeout.
+func (r *Runtime) stopServices(services []IsRuntimeService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if r.shutdownTimeout > 0 {
+		timeout := r.clock.After(r.shutdownTimeout)
+
+		go func() {
+			select {
+			case <-timeout:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	for _, service := range r.dependentsFirst(services) {
+		hook, ok := service.(HasStopHook)
+		if !ok {
+			continue
+		}
+
+		r.log().Debug().Msgf("stopping %q service", r.ServiceName(service))
+
+		if err := hook.OnStop(ctx); err != nil {
+			r.ReportError(service, fmt.Errorf("stopping: %w", err), false)
+		}
+	}
+}
+
+// stopLate calls the OnStop hooks of services that are shut down while the
+// runtime keeps running, such as services that are removed, replaced or
+// restarted, and forgets that they were started.
+func (r *Runtime) stopLate(services []IsRuntimeService) {
+	r.stopServices(services)
+
+	r.servicesMu.Lock()
+	for _, service := range services {
+		delete(r.startedServices, service)
+	}
+	r.servicesMu.Unlock()
+}
+
+// servicesStopped calls the OnStopped hooks of the services, dependents
+// first.
+func (r *Runtime) servicesStopped(services []IsRuntimeService) {
+	for _, service := range r.dependentsFirst(services) {
+		if hook, ok := service.(HasStoppedHook); ok {
+			hook.OnStopped()
+		}
+	}
+}
+
+// hookedServices returns the services of the runtime, without the runtime
+// itself.
+func (r *Runtime) hookedServices() []IsRuntimeService {
+	services := make([]IsRuntimeService, 0)
+
+	for _, service := range r.Services() {
+		if service != r {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// lifecycleLog records the calls of the hooks of services.
+type lifecycleLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *lifecycleLog) record(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, call)
+}
+
+func (l *lifecycleLog) Calls() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string{}, l.calls...)
+}
+
+type hookedService struct {
+	declaringService
+	log      *lifecycleLog
+	startErr error
+}
+
+func (s *hookedService) Init(_ IsRuntime)  { s.log.record("init " + s.name) }
+func (s *hookedService) OnAllInitialized() { s.log.record("all initialized " + s.name) }
+func (s *hookedService) OnShutdown()       { s.log.record("shutdown " + s.name) }
+func (s *hookedService) OnStopped()        { s.log.record("stopped " + s.name) }
+
+func (s *hookedService) OnStart(_ context.Context) error {
+	s.log.record("start " + s.name)
+	return s.startErr
+}
+
+func (s *hookedService) OnStop(_ context.Context) error {
+	s.log.record("stop " + s.name)
+	return nil
+}
+
+func TestRuntime_LifecycleHooks(t *testing.T) {
+	rt := New("lifecycle", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	log := &lifecycleLog{}
+
+	api := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "api"}, dependsOn: []string{"db"}}}
+	db := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "db"}}}
+
+	// added in the reverse order of their dependencies
+	rt.Add(api)
+	rt.Add(db)
+
+	running := make(chan struct{})
+
+	rt.Events().Subscribe(events.EventRuntimeRunLoopInitiated, func(...any) {
+		log.record("run loop")
+		close(running)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		rt.Run()
+		close(done)
+	}()
+
+	<-running
+	rt.Shutdown().Wait()
+	<-done
+
+	expected := []string{
+		"init db", "init api",
+		"all initialized db", "all initialized api",
+		"start db", "start api",
+		"run loop",
+		"stop api", "stop db",
+		"shutdown api", "shutdown db",
+		"stopped api", "stopped db",
+	}
+
+	if calls := log.Calls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("unexpected order of hooks:\n%v\nexpected:\n%v", calls, expected)
+	}
+}
+
+func TestRuntime_StartFailure(t *testing.T) {
+	rt := New("lifecycle", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	log := &lifecycleLog{}
+
+	db := &hookedService{log: log, startErr: errors.New("no connection"), declaringService: declaringService{countingService: countingService{name: "db"}}}
+	api := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "api"}, dependsOn: []string{"db"}}}
+
+	rt.Add(db)
+	rt.Add(api)
+
+	// returns once the runtime is shut down
+	rt.Run()
+
+	for _, call := range log.Calls() {
+		if call == "start api" {
+			t.Error("expected the api not to be started after its dependency failed to start")
+		}
+	}
+
+	if rt.StateOf(api) != ServiceStateStopped {
+		t.Error("expected the runtime to be shut down")
+	}
+}
+
+func TestRuntime_LateLifecycleHooks(t *testing.T) {
+	rt := New("lifecycle", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	log := &lifecycleLog{}
+
+	db := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "db"}}}
+	rt.Add(db)
+
+	done := make(chan struct{})
+
+	go func() {
+		rt.Run()
+		close(done)
+	}()
+
+	if err := rt.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(step string, expected ...string) {
+		t.Helper()
+
+		if calls := log.Calls(); !reflect.DeepEqual(calls, expected) {
+			t.Errorf("%s: unexpected hooks:\n%v\nexpected:\n%v", step, calls, expected)
+		}
+
+		log.mu.Lock()
+		log.calls = nil
+		log.mu.Unlock()
+	}
+
+	expect("run", "init db", "all initialized db", "start db")
+
+	cache := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "cache"}}}
+	rt.Add(cache).Wait()
+
+	expect("add", "init cache", "all initialized cache", "start cache")
+
+	if notReady := rt.NotReady(); len(notReady) != 0 {
+		t.Errorf("expected the added service to be ready, got %v", notReady)
+	}
+
+	replacement := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "cache v2"}, dependsOn: []string{"db"}}}
+
+	if err := rt.Replace(cache, replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("replace",
+		"init cache v2", "all initialized cache v2", "start cache v2",
+		"stop cache", "shutdown cache", "stopped cache",
+	)
+
+	rt.Restart().Wait()
+
+	expect("restart",
+		"stop cache v2", "stop db",
+		"shutdown cache v2", "shutdown db",
+		"stopped cache v2", "stopped db",
+		"init db", "init cache v2",
+		"all initialized db", "all initialized cache v2",
+		"start db", "start cache v2",
+	)
+
+	rt.Remove(replacement).Wait()
+
+	expect("remove", "stop cache v2", "stopped cache v2")
+
+	rt.servicesMu.RLock()
+	started := len(rt.startedServices)
+	rt.servicesMu.RUnlock()
+
+	if started != 1 {
+		t.Errorf("expected only the db to be started, got %d services", started)
+	}
+
+	if notReady := rt.NotReady(); len(notReady) != 0 {
+		t.Errorf("expected the runtime to be ready, got %v", notReady)
+	}
+
+	rt.Shutdown().Wait()
+	<-done
+}
+
+func TestRuntime_ShutdownOrder(t *testing.T) {
+	rt := New("lifecycle", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	log := &lifecycleLog{}
+
+	db := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "db"}}}
+	api := &hookedService{log: log, declaringService: declaringService{countingService: countingService{name: "api"}, dependsOn: []string{"db"}}}
+
+	// added in the order of their dependencies
+	rt.Add(db).Wait()
+	rt.Add(api).Wait()
+
+	rt.Shutdown().Wait()
+
+	expected := []string{
+		"init db", "init api",
+		"stop api", "stop db",
+		"shutdown api", "shutdown db",
+		"stopped api", "stopped db",
+	}
+
+	if calls := log.Calls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("unexpected order of hooks:\n%v\nexpected:\n%v", calls, expected)
+	}
+}
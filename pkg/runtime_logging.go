@@ -3,64 +3,308 @@ package pkg
 import (
 	"fmt"
 	"io"
+	"path"
+	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-	"github.com/rs/zerolog/pkgerrors"
 )
 
+// LogFormat is the format of the log output of the runtime and its services.
+type LogFormat int
+
+const (
+	// LogFormatConsole writes colored, human-readable lines, with the name
+	// of the service as a prefix of the message. This is the default.
+	LogFormatConsole LogFormat = iota
+
+	// LogFormatJSON writes a JSON object per line, with the name of the
+	// service and of the runtime as fields, a timestamp and the caller.
+	LogFormatJSON
+)
+
+// String returns the name of the log format.
+func (f LogFormat) String() string {
+	switch f {
+	case LogFormatJSON:
+		return "json"
+	default:
+		return "console"
+	}
+}
+
 // newLogger is a factory function that generates a zerolog.Logger
 func (r *Runtime) newLogger(service interface{ Name() string }, level zerolog.Level, dst io.Writer) *zerolog.Logger {
-	name := service.Name()
+	return r.buildLogger(service.Name(), level, dst, true)
+}
+
+// buildLogger generates a zerolog.Logger for the named service. The caller
+// is only added by the logger itself when withCaller is set, as it is wrong
+// for loggers that are wrapped, such as the slog handler.
+//
+// The logger only depends on the settings of the runtime, and not on the
+// global settings of zerolog, so that runtimes do not interfere.
+func (r *Runtime) buildLogger(name string, level zerolog.Level, dst io.Writer, withCaller bool) *zerolog.Logger {
+	r.logMu.Lock()
+	format := r.logFormat
+	timestamp := timestampHook{layout: r.logTimeFormat, clock: r.clock}
+	base := r.baseLogger
+	r.logMu.Unlock()
+
+	if base != nil {
+		logger := base.With().Str("service", name).Logger().Level(level)
+		return &logger
+	}
+
+	if format == LogFormatJSON {
+		if timestamp.layout == "" {
+			timestamp.layout = time.RFC3339
+		}
+
+		ctx := zerolog.New(r.teeLogCapture(name, dst)).Hook(timestamp).With()
+
+		if withCaller {
+			ctx = ctx.Caller()
+		}
+
+		logger := ctx.
+			Str("runtime", r.name).
+			Str("service", name).
+			Logger().
+			Level(level)
+
+		return &logger
+	}
+
+	if timestamp.layout == "" {
+		timestamp.layout = time.Kitchen
+	}
 
 	writer := zerolog.ConsoleWriter{
 		Out: dst,
+		FormatTimestamp: func(input any) string {
+			// the timestamp is already formatted by the hook
+			return fmt.Sprintf("\x1b[90m%v\x1b[0m", input)
+		},
 		FormatMessage: func(input any) string {
 			return fmt.Sprintf("[%s]: %s", name, input)
 		},
 	}
 
-	logger := log.Output(writer).With().Logger().Level(level)
-
-	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	logger := zerolog.New(r.teeLogCapture(name, writer)).Hook(timestamp).Level(level)
 
 	return &logger
 }
 
+// timestampHook adds the time of the clock to every log line, formatted with
+// the given layout rather than with the global zerolog.TimeFieldFormat.
+type timestampHook struct {
+	layout string
+	clock  Clock
+}
+
+func (h timestampHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	e.Str(zerolog.TimestampFieldName, h.clock.Now().Format(h.layout))
+}
+
 func (r *Runtime) SetLogLevel(level zerolog.Level) {
-	r.logger.Info().Msgf("setting log level to %s", level)
+	r.log().Info().Msgf("setting log level to %s", level)
 
+	r.logMu.Lock()
 	r.logLevel = level
+	r.logMu.Unlock()
+
+	// set the log level for the runtime and each service that has a logger
+	r.rebindLoggers()
+}
+
+func (r *Runtime) SetLogDestination(dst io.Writer) {
+	r.logMu.Lock()
+	r.logOutput = dst
+	r.logMu.Unlock()
+
+	// set the log destination for the runtime and each service that has a logger
+	r.rebindLoggers()
+}
+
+// SetLogFormat sets the format of the log output of the runtime and of every
+// service that has a logger.
+func (r *Runtime) SetLogFormat(format LogFormat) {
+	r.logMu.Lock()
+	r.logFormat = format
+	r.logMu.Unlock()
+
+	r.rebindLoggers()
+}
+
+// log yields the logger of the runtime itself.
+func (r *Runtime) log() *zerolog.Logger {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+
+	return r.logger
+}
+
+// SetServiceLogLevel overrides the log level of the services whose name
+// matches the given pattern, which is either a service name or a pattern
+// as understood by path.Match, eg "db.*". When several overrides match a
+// service, the one set last wins.
+func (r *Runtime) SetServiceLogLevel(pattern string, level zerolog.Level) {
+	r.log().Info().Msgf("setting log level of services matching %q to %s", pattern, level)
+
+	r.setLogOverride(logOverride{pattern: pattern, level: &level})
+	r.rebindLoggers()
+}
+
+// SetServiceLogDestination overrides the log destination of the services
+// whose name matches the given pattern, like SetServiceLogLevel.
+func (r *Runtime) SetServiceLogDestination(pattern string, dst io.Writer) {
+	r.setLogOverride(logOverride{pattern: pattern, dst: dst})
+	r.rebindLoggers()
+}
+
+// ResetServiceLogging removes the log level and destination overrides that
+// were set with the given pattern.
+func (r *Runtime) ResetServiceLogging(pattern string) {
+	r.logMu.Lock()
+
+	overrides := make([]logOverride, 0, len(r.logOverrides))
+	for _, override := range r.logOverrides {
+		if override.pattern != pattern {
+			overrides = append(overrides, override)
+		}
+	}
+
+	r.logOverrides = overrides
+
+	r.logMu.Unlock()
+
+	r.rebindLoggers()
+}
 
-	// set the log-level for the runtime's logger
-	r.logger = r.newLogger(r, r.logLevel, r.logOutput)
+// ServiceLogLevel returns the effective log level of the named service.
+func (r *Runtime) ServiceLogLevel(name string) zerolog.Level {
+	level, _ := r.serviceLogSettings(name)
+	return level
+}
+
+// ServiceLogLevels returns the effective log level of every service that
+// has a logger, by service name.
+func (r *Runtime) ServiceLogLevels() map[string]zerolog.Level {
+	levels := make(map[string]zerolog.Level)
 
-	// set the log level for each service that has a logger
 	for _, service := range r.Services() {
-		candidate, ok := service.(HasLogger)
-		if !ok {
-			continue
+		_, hasLogger := service.(HasLogger)
+		_, hasSlogLogger := service.(HasSlogLogger)
+
+		if hasLogger || hasSlogLogger {
+			name := r.ServiceName(service)
+			levels[name] = r.ServiceLogLevel(name)
 		}
+	}
+
+	return levels
+}
+
+// logOverride is a log level or destination for the services matching a
+// pattern. A nil level or destination is not overridden.
+type logOverride struct {
+	pattern string
+	level   *zerolog.Level
+	dst     io.Writer
+}
 
-		candidateLogger := r.newLogger(candidate, r.logLevel, r.logOutput)
-		candidate.BindLogger(candidateLogger)
+func (o logOverride) matches(name string) bool {
+	return matchServiceName(o.pattern, name)
+}
+
+// matchServiceName returns true if the pattern is the name of the service,
+// or a pattern as understood by path.Match that matches it.
+func matchServiceName(pattern, name string) bool {
+	if pattern == name {
+		return true
 	}
+
+	matched, err := path.Match(pattern, name)
+
+	return err == nil && matched
 }
 
-func (r *Runtime) SetLogDestination(dst io.Writer) {
-	r.logOutput = dst
+func (r *Runtime) setLogOverride(override logOverride) {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
 
-	newLogger := r.newLogger(r, r.logLevel, r.logOutput)
-	r.logger = newLogger
+	r.logOverrides = append(r.logOverrides, override)
+}
 
-	// set the log level for each service that has a logger
-	for _, service := range r.Services() {
-		candidate, ok := service.(HasLogger)
-		if !ok {
+// serviceLogSettings yields the effective log level and destination of the
+// named service, taking the overrides into account.
+func (r *Runtime) serviceLogSettings(name string) (zerolog.Level, io.Writer) {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+
+	level, dst := r.logLevel, r.logOutput
+
+	levelSet, dstSet := false, false
+
+	overrides := append(append([]logOverride{}, r.inheritedOverrides...), r.logOverrides...)
+
+	// the last override wins, so walk them backwards
+	for i := len(overrides) - 1; i >= 0; i-- {
+		override := overrides[i]
+		if !override.matches(name) {
 			continue
 		}
 
-		candidateLogger := r.newLogger(candidate, r.logLevel, r.logOutput)
-		candidate.BindLogger(candidateLogger)
+		if override.level != nil && !levelSet {
+			level, levelSet = *override.level, true
+		}
+
+		if override.dst != nil && !dstSet {
+			dst, dstSet = override.dst, true
+		}
+	}
+
+	return level, dst
+}
+
+// bindLogger binds new loggers to the service, with its effective log
+// level and destination. It returns false if the service has no logger.
+func (r *Runtime) bindLogger(service IsRuntimeService) bool {
+	name := instanceName(r.ServiceName(service))
+	level, dst := r.serviceLogSettings(name.Name())
+	bound := false
+
+	if candidate, ok := service.(HasLogger); ok {
+		candidate.BindLogger(r.newLogger(name, level, dst))
+		bound = true
+	}
+
+	if candidate, ok := service.(HasSlogLogger); ok {
+		candidate.BindSlogLogger(r.newSlogLogger(name, level, dst))
+		bound = true
+	}
+
+	return bound
+}
+
+// rebindLoggers binds a new logger to the runtime, and new loggers to each
+// service that has a logger.
+func (r *Runtime) rebindLoggers() {
+	r.logMu.Lock()
+	level, dst := r.logLevel, r.logOutput
+	r.logMu.Unlock()
+
+	logger := r.newLogger(r, level, dst)
+
+	r.logMu.Lock()
+	r.logger = logger
+	r.logMu.Unlock()
+
+	for _, service := range r.Services() {
+		r.bindLogger(service)
+	}
+
+	for _, child := range r.Children() {
+		child.inheritLogging(r)
 	}
 }
@@ -0,0 +1,265 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultLogCaptureSize is the number of log records retained per service
+// when log capture is enabled with a size of zero.
+const DefaultLogCaptureSize = 512
+
+// LogRecord is a log line captured by a LogCapture.
+type LogRecord struct {
+	// Service is the name of the service that logged the line.
+	Service string
+
+	// Level is the level the line was logged at.
+	Level zerolog.Level
+
+	// Time is when the line was captured, on the clock of the runtime.
+	Time time.Time
+
+	// Message is the message of the line, without the service prefix.
+	Message string
+
+	// Fields holds the other fields of the line, such as errors.
+	Fields map[string]any
+}
+
+// LogQuery selects captured log records. The zero value selects every record
+// at debug level or above.
+type LogQuery struct {
+	// Service is a service name, or a pattern as understood by path.Match.
+	// Empty selects every service.
+	Service string
+
+	// Level is the minimum level of the records.
+	Level zerolog.Level
+
+	// Since and Until bound the time of the records. Zero values are not
+	// bounded.
+	Since time.Time
+	Until time.Time
+
+	// Limit is the maximum number of records, keeping the most recent
+	// ones. Zero is not limited.
+	Limit int
+}
+
+// LogCapture retains the most recent log records of every service in a
+// bounded buffer per service, so that they can be inspected at runtime.
+type LogCapture struct {
+	size int
+
+	mu       sync.Mutex
+	services map[string]*logRing
+}
+
+// logRing is a ring buffer of the log records of a service.
+type logRing struct {
+	records []LogRecord // oldest record at head
+	head    int
+	size    int
+}
+
+// NewLogCapture creates a log capture that retains up to size records per
+// service.
+func NewLogCapture(size int) *LogCapture {
+	if size <= 0 {
+		size = DefaultLogCaptureSize
+	}
+
+	return &LogCapture{
+		size:     size,
+		services: make(map[string]*logRing),
+	}
+}
+
+// Query returns the captured records matching the query, oldest first.
+func (c *LogCapture) Query(query LogQuery) []LogRecord {
+	c.mu.Lock()
+
+	records := make([]LogRecord, 0)
+
+	for name, captured := range c.services {
+		if query.Service != "" && !matchServiceName(query.Service, name) {
+			continue
+		}
+
+		for i := 0; i < captured.size; i++ {
+			record := captured.records[(captured.head+i)%len(captured.records)]
+			if query.matches(record) {
+				records = append(records, record)
+			}
+		}
+	}
+
+	c.mu.Unlock()
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[len(records)-query.Limit:]
+	}
+
+	return records
+}
+
+// Services returns the names of the services that have captured records.
+func (c *LogCapture) Services() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.services))
+	for name := range c.services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Reset discards every captured record.
+func (c *LogCapture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.services = make(map[string]*logRing)
+}
+
+func (c *LogCapture) add(record LogRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ring, found := c.services[record.Service]
+	if !found {
+		ring = &logRing{records: make([]LogRecord, c.size)}
+		c.services[record.Service] = ring
+	}
+
+	ring.push(record)
+}
+
+func (r *logRing) push(record LogRecord) {
+	if r.size < len(r.records) {
+		r.records[(r.head+r.size)%len(r.records)] = record
+		r.size++
+		return
+	}
+
+	// the buffer is full, overwrite the oldest record
+	r.records[r.head] = record
+	r.head = (r.head + 1) % len(r.records)
+}
+
+func (q LogQuery) matches(record LogRecord) bool {
+	if record.Level < q.Level {
+		return false
+	}
+
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+
+	return true
+}
+
+// logCaptureWriter receives the JSON lines of the logger of a service, and
+// captures them as records.
+type logCaptureWriter struct {
+	capture *LogCapture
+	service string
+	clock   Clock
+}
+
+func (w *logCaptureWriter) Write(p []byte) (int, error) {
+	fields := make(map[string]any)
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return len(p), nil // not a log line, nothing to capture
+	}
+
+	record := LogRecord{
+		Service: w.service,
+		Level:   zerolog.NoLevel,
+		Time:    w.clock.Now(),
+		Fields:  fields,
+	}
+
+	if level, ok := fields[zerolog.LevelFieldName].(string); ok {
+		if parsed, err := zerolog.ParseLevel(level); err == nil {
+			record.Level = parsed
+		}
+	}
+
+	record.Message, _ = fields[zerolog.MessageFieldName].(string)
+
+	for _, key := range []string{zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.TimestampFieldName} {
+		delete(fields, key)
+	}
+
+	w.capture.add(record)
+
+	return len(p), nil
+}
+
+// SetLogCapture enables the capture of the log records of the runtime and its
+// services, retaining up to size records per service, or
+// DefaultLogCaptureSize if size is zero. A negative size disables log
+// capture.
+func (r *Runtime) SetLogCapture(size int) {
+	r.logMu.Lock()
+
+	if size < 0 {
+		r.logCapture = nil
+	} else {
+		r.logCapture = NewLogCapture(size)
+	}
+
+	r.logMu.Unlock()
+
+	r.rebindLoggers()
+}
+
+// LogCapture yields the log capture of the runtime, or nil if log capture
+// is not enabled.
+func (r *Runtime) LogCapture() *LogCapture {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+
+	return r.logCapture
+}
+
+// Logs returns the captured log records matching the query. It returns
+// nothing if log capture is not enabled.
+func (r *Runtime) Logs(query LogQuery) []LogRecord {
+	capture := r.LogCapture()
+	if capture == nil {
+		return nil
+	}
+
+	return capture.Query(query)
+}
+
+// teeLogCapture yields a writer that writes to dst, and captures the log
+// lines of the named service if log capture is enabled.
+func (r *Runtime) teeLogCapture(name string, dst io.Writer) io.Writer {
+	capture := r.LogCapture()
+	if capture == nil {
+		return dst
+	}
+
+	return zerolog.MultiLevelWriter(dst, &logCaptureWriter{capture: capture, service: name, clock: r.clock})
+}
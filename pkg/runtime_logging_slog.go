@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+var _ slog.Handler = &slogHandler{}
+
+// NewSlogHandler yields a slog.Handler that writes the records through the
+// given zerolog logger, honoring its level.
+func NewSlogHandler(logger *zerolog.Logger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+// newSlogLogger is a factory function that generates a slog.Logger backed by
+// the same output as the zerolog loggers of the runtime.
+func (r *Runtime) newSlogLogger(service interface{ Name() string }, level zerolog.Level, dst io.Writer) *slog.Logger {
+	r.logMu.Lock()
+	format := r.logFormat
+	r.logMu.Unlock()
+
+	handler := &slogHandler{
+		logger:     r.buildLogger(service.Name(), level, dst, false),
+		withCaller: format == LogFormatJSON,
+	}
+
+	return slog.New(handler)
+}
+
+// slogHandler writes slog records through a zerolog logger. Attributes of
+// groups are flattened into fields named "group.attribute".
+//
+// The handler is immutable, WithAttrs and WithGroup yield modified copies.
+type slogHandler struct {
+	logger     *zerolog.Logger
+	withCaller bool
+	attrs      []slog.Attr
+	groups     []string
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return zerologLevel(level) >= h.logger.GetLevel()
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	event := h.logger.WithLevel(zerologLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	if h.withCaller && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		event.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(record.PC, frame.File, frame.Line))
+	}
+
+	// the attributes of the handler are already qualified with their groups
+	for _, attr := range h.attrs {
+		addSlogAttr(event, "", attr)
+	}
+
+	prefix := groupPrefix(h.groups)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(event, prefix, attr)
+		return true
+	})
+
+	event.Msg(record.Message)
+
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+
+	// attributes are qualified with the groups that are open when they
+	// are added, so the open groups are applied to them now
+	prefix := groupPrefix(h.groups)
+	qualified := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	qualified = append(qualified, h.attrs...)
+
+	for _, attr := range attrs {
+		if attr.Key != "" {
+			attr.Key = prefix + attr.Key
+		}
+
+		qualified = append(qualified, attr)
+	}
+
+	clone.attrs = qualified
+
+	return &clone
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.groups = append(append([]string{}, h.groups...), name)
+
+	return &clone
+}
+
+func addSlogAttr(event *zerolog.Event, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := prefix + attr.Key
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := key + "."
+		if attr.Key == "" {
+			groupPrefix = prefix // inline the attributes of unnamed groups
+		}
+
+		for _, member := range attr.Value.Group() {
+			addSlogAttr(event, groupPrefix, member)
+		}
+	case slog.KindString:
+		event.Str(key, attr.Value.String())
+	case slog.KindInt64:
+		event.Int64(key, attr.Value.Int64())
+	case slog.KindUint64:
+		event.Uint64(key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		event.Float64(key, attr.Value.Float64())
+	case slog.KindBool:
+		event.Bool(key, attr.Value.Bool())
+	case slog.KindDuration:
+		event.Dur(key, attr.Value.Duration())
+	case slog.KindTime:
+		event.Time(key, attr.Value.Time())
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			event.AnErr(key, err)
+			return
+		}
+
+		event.Interface(key, attr.Value.Any())
+	}
+}
+
+func groupPrefix(groups []string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+
+	return strings.Join(groups, ".") + "."
+}
+
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	case level >= slog.LevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
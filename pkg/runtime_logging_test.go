@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRuntime_SetLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	rt := New("json test")
+	rt.SetLogLevel(zerolog.InfoLevel)
+	rt.SetLogDestination(&buf)
+	rt.SetLogFormat(LogFormatJSON)
+
+	svc := &exampleService{}
+	rt.Add(svc).Wait()
+
+	buf.Reset()
+	svc.Logger().Info().Msg("hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]any{
+		"service": svc.Name(),
+		"runtime": "json test",
+		"message": "hello",
+		"level":   "info",
+	}
+
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("expected %q to be %q, got %q", key, value, line[key])
+		}
+	}
+
+	for _, key := range []string{"time", "caller"} {
+		if _, found := line[key]; !found {
+			t.Errorf("expected %q field in %q", key, strings.TrimSpace(buf.String()))
+		}
+	}
+}
+
+type namedLoggerService struct {
+	exampleService
+	name string
+}
+
+func (s *namedLoggerService) Name() string {
+	return s.name
+}
+
+func TestRuntime_SetServiceLogLevel(t *testing.T) {
+	var dbOutput bytes.Buffer
+
+	rt := New("test")
+	rt.SetLogLevel(zerolog.InfoLevel)
+	rt.SetLogDestination(&bytes.Buffer{})
+
+	users := &namedLoggerService{name: "db.users"}
+	api := &namedLoggerService{name: "api"}
+
+	rt.Add(users).Wait()
+	rt.Add(api).Wait()
+
+	rt.SetServiceLogLevel("db.*", zerolog.DebugLevel)
+	rt.SetServiceLogDestination("db.*", &dbOutput)
+
+	if level := users.Logger().GetLevel(); level != zerolog.DebugLevel {
+		t.Errorf("expected debug level for matching service, got %s", level)
+	}
+
+	if level := api.Logger().GetLevel(); level != zerolog.InfoLevel {
+		t.Errorf("expected info level for other service, got %s", level)
+	}
+
+	// overrides are preserved when the loggers are bound again
+	rt.SetLogLevel(zerolog.WarnLevel)
+
+	levels := rt.ServiceLogLevels()
+	if levels["db.users"] != zerolog.DebugLevel || levels["api"] != zerolog.WarnLevel {
+		t.Errorf("unexpected effective log levels: %v", levels)
+	}
+
+	users.Logger().Debug().Msg("query")
+	if !strings.Contains(dbOutput.String(), "query") {
+		t.Errorf("expected log line in service destination, got %q", dbOutput.String())
+	}
+
+	rt.ResetServiceLogging("db.*")
+
+	if level := rt.ServiceLogLevel("db.users"); level != zerolog.WarnLevel {
+		t.Errorf("expected override to be reset, got %s", level)
+	}
+}
+
+type slogService struct {
+	logger *slog.Logger
+}
+
+func (s *slogService) Init(_ IsRuntime)                   {}
+func (s *slogService) Name() string                       { return "slog" }
+func (s *slogService) BindSlogLogger(logger *slog.Logger) { s.logger = logger }
+func (s *slogService) SlogLogger() *slog.Logger           { return s.logger }
+
+func TestRuntime_SlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	rt := New("test")
+	rt.SetLogLevel(zerolog.InfoLevel)
+	rt.SetLogDestination(&bytes.Buffer{})
+	rt.SetLogFormat(LogFormatJSON)
+
+	svc := &slogService{}
+	rt.Add(svc).Wait()
+
+	rt.SetServiceLogDestination(svc.Name(), &buf)
+
+	svc.SlogLogger().Debug("filtered")
+	svc.SlogLogger().WithGroup("req").With("id", 7).Warn("slow", "ms", 250)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single JSON log line, got %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]any{
+		"service": "slog",
+		"level":   "warn",
+		"message": "slow",
+		"req.id":  float64(7),
+		"req.ms":  float64(250),
+	}
+
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("expected %q to be %v, got %v", key, value, line[key])
+		}
+	}
+
+	if caller, _ := line["caller"].(string); !strings.Contains(caller, "runtime_logging_test.go") {
+		t.Errorf("expected caller to be the test, got %q", caller)
+	}
+}
+
+func TestRuntime_LoggingOptions(t *testing.T) {
+	var first, second bytes.Buffer
+
+	rtA := New("first", WithLogDestination(&first), WithLogFormat(LogFormatJSON), WithLogTimeFormat(time.DateOnly))
+	rtB := New("second", WithLogDestination(&second), WithLogLevel(zerolog.WarnLevel))
+
+	// changing the destination keeps the initial log level
+	rtA.SetLogDestination(&first)
+
+	if level := rtA.ServiceLogLevel("any"); level != zerolog.InfoLevel {
+		t.Errorf("expected default log level to be info, got %s", level)
+	}
+
+	if level := rtB.ServiceLogLevel("any"); level != zerolog.WarnLevel {
+		t.Errorf("expected log level option to apply, got %s", level)
+	}
+
+	first.Reset()
+	rtA.log().Info().Msg("hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(first.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", first.String(), err)
+	}
+
+	// the shape of the date is checked, so that the test passes across
+	// midnight
+	if stamp, _ := line["time"].(string); !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(stamp) {
+		t.Errorf("expected time format option to apply, got %v", line["time"])
+	}
+
+	if second.Len() != 0 {
+		t.Errorf("expected no info output from the second runtime, got %q", second.String())
+	}
+}
+
+func TestLogCapture_Bounded(t *testing.T) {
+	capture := NewLogCapture(3)
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		capture.add(LogRecord{Service: "svc", Time: start.Add(time.Duration(i)), Message: fmt.Sprint(i)})
+	}
+
+	records := capture.Query(LogQuery{})
+	if len(records) != 3 {
+		t.Fatalf("expected 3 retained records, got %d", len(records))
+	}
+
+	for i, record := range records {
+		if expected := fmt.Sprint(i + 2); record.Message != expected {
+			t.Errorf("expected record %q, got %q", expected, record.Message)
+		}
+	}
+}
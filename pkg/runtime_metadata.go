@@ -0,0 +1,94 @@
+package pkg
+
+// Metadata describes a service, to organize services beyond their names.
+type Metadata struct {
+	// Version is the version of the service, eg "1.4.2".
+	Version string `json:"version,omitempty"`
+
+	// Description says what the service does.
+	Description string `json:"description,omitempty"`
+
+	// Owner is who is responsible for the service, eg a team.
+	Owner string `json:"owner,omitempty"`
+
+	// Tags are arbitrary labels for grouping services, eg "storage".
+	Tags []string `json:"tags,omitempty"`
+}
+
+// HasTag returns true if the metadata has the given tag.
+func (m Metadata) HasTag(tag string) bool {
+	for _, candidate := range m.Tags {
+		if candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ServiceMetadata returns the metadata of the service, if it implements
+// HasMetadata.
+func ServiceMetadata(service IsRuntimeService) (Metadata, bool) {
+	if described, ok := service.(HasMetadata); ok {
+		return described.Metadata(), true
+	}
+
+	return Metadata{}, false
+}
+
+// ServicesTagged returns the services that have every one of the given tags,
+// in the order they were added.
+func (r *Runtime) ServicesTagged(tags ...string) []IsRuntimeService {
+	services := make([]IsRuntimeService, 0)
+
+	for _, service := range r.Services() {
+		metadata, ok := ServiceMetadata(service)
+		if !ok {
+			continue
+		}
+
+		tagged := true
+
+		for _, tag := range tags {
+			if !metadata.HasTag(tag) {
+				tagged = false
+				break
+			}
+		}
+
+		if tagged {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+// logMetadata logs the metadata of the service, if it has any.
+func (r *Runtime) logMetadata(name string, service IsRuntimeService) {
+	metadata, ok := ServiceMetadata(service)
+	if !ok {
+		return
+	}
+
+	event := r.log().Info()
+
+	if metadata.Version != "" {
+		event = event.Str("version", metadata.Version)
+	}
+
+	if metadata.Owner != "" {
+		event = event.Str("owner", metadata.Owner)
+	}
+
+	if len(metadata.Tags) > 0 {
+		event = event.Strs("tags", metadata.Tags)
+	}
+
+	if metadata.Description != "" {
+		event.Msgf("service %q: %s", name, metadata.Description)
+		return
+	}
+
+	event.Msgf("service %q", name)
+}
@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+type describedService struct {
+	countingService
+	metadata Metadata
+}
+
+func (s *describedService) Metadata() Metadata {
+	return s.metadata
+}
+
+func TestRuntime_ServicesTagged(t *testing.T) {
+	var buf lockedBuffer
+
+	rt := New("metadata", WithoutSignalHandling(), WithLogDestination(&buf), WithLogFormat(LogFormatJSON))
+
+	db := &describedService{
+		countingService: countingService{name: "db"},
+		metadata:        Metadata{Version: "2.1.0", Description: "stores things", Tags: []string{"storage", "sql"}},
+	}
+
+	blobs := &describedService{
+		countingService: countingService{name: "blobs"},
+		metadata:        Metadata{Tags: []string{"storage"}},
+	}
+
+	rt.Add(db).Wait()
+	rt.Add(blobs).Wait()
+	rt.Add(&countingService{name: "plain"}).Wait()
+
+	if tagged := rt.ServicesTagged("storage"); len(tagged) != 2 {
+		t.Errorf("expected two storage services, got %d", len(tagged))
+	}
+
+	if tagged := rt.ServicesTagged("storage", "sql"); len(tagged) != 1 || tagged[0] != db {
+		t.Errorf("expected only the database, got %v", tagged)
+	}
+
+	if !strings.Contains(buf.String(), `"version":"2.1.0"`) || !strings.Contains(buf.String(), "stores things") {
+		t.Errorf("expected the metadata to be logged, got %q", buf.String())
+	}
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Option configures a Runtime when it is created with New.
+type Option func(*Runtime)
+
+// WithLogLevel sets the initial log level of the runtime and its services.
+// Defaults to zerolog.InfoLevel.
+func WithLogLevel(level zerolog.Level) Option {
+	return func(r *Runtime) {
+		r.logLevel = level
+	}
+}
+
+// WithLogDestination sets the initial log destination of the runtime and its
+// services. Defaults to os.Stdout.
+func WithLogDestination(dst io.Writer) Option {
+	return func(r *Runtime) {
+		r.logOutput = dst
+	}
+}
+
+// WithLogFormat sets the initial log format of the runtime and its services.
+// Defaults to LogFormatConsole.
+func WithLogFormat(format LogFormat) Option {
+	return func(r *Runtime) {
+		r.logFormat = format
+	}
+}
+
+// WithLogTimeFormat sets the layout of the timestamps of the log output, as
+// understood by time.Format. Defaults to time.Kitchen for LogFormatConsole,
+// and to time.RFC3339 for LogFormatJSON.
+func WithLogTimeFormat(layout string) Option {
+	return func(r *Runtime) {
+		r.logTimeFormat = layout
+	}
+}
+
+// WithLogger sets the logger of the runtime itself. The loggers of services
+// are derived from it, with the name of the service as a field, and with
+// their effective log level. The log destination, format and time format
+// options do not apply to loggers derived from it, and neither does log
+// capture.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(r *Runtime) {
+		r.baseLogger = &logger
+	}
+}
+
+// WithSignals sets the signals upon which the run loop of the runtime shuts
+// it down. Defaults to os.Interrupt.
+func WithSignals(signals ...os.Signal) Option {
+	return func(r *Runtime) {
+		r.signals = signals
+	}
+}
+
+// WithoutSignalHandling prevents the runtime from handling any signal, for
+// when the runtime is embedded in an application that handles them itself.
+// The run loop then only ends when Shutdown is called.
+func WithoutSignalHandling() Option {
+	return WithSignals()
+}
+
+// WithShutdownTimeout sets how long Shutdown waits for the services to shut
+// down gracefully before giving up on them. Zero, the default, waits
+// indefinitely.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.shutdownTimeout = timeout
+	}
+}
+
+// WithStartupTimeout sets how long Run waits for the services to be
+// initialized and started. When it elapses, the runtime fails with
+// ErrStartupTimeout, listing the services that are not ready, and shuts
+// down. Zero, the default, waits indefinitely.
+func WithStartupTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.startupTimeout = timeout
+	}
+}
+
+// WithOptionalDependencyTimeout sets how long services wait for their
+// optional dependencies before they are initialized without them. Defaults
+// to DefaultOptionalDependencyTimeout.
+func WithOptionalDependencyTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.optionalDependencyTimeout = timeout
+	}
+}
+
+// WithTaskShutdownTimeout sets how long the runtime waits for the tasks of a
+// service to return once they are cancelled. Defaults to
+// DefaultTaskShutdownTimeout.
+func WithTaskShutdownTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.taskShutdownTimeout = timeout
+	}
+}
+
+// WithLeakDetection enables the detection of goroutines that outlive their
+// service: the tasks that did not return, and the goroutines started by the
+// methods of the service that are still running after it was shut down.
+// Leaks are logged with their stack traces, and reported by Runtime.Leaks.
+//
+// Leak detection takes a stack trace of every goroutine as services are
+// initialized and shut down, which is meant for debugging and tests.
+func WithLeakDetection() Option {
+	return func(r *Runtime) {
+		r.detectLeaks = true
+	}
+}
+
+// WithEventBus sets the event bus of the runtime, eg to share one between
+// runtimes, or to retain a different number of events.
+func WithEventBus(bus *EventBus) Option {
+	return func(r *Runtime) {
+		r.events = bus
+	}
+}
+
+// WithEventForwarding sets which events of the runtime are re-emitted on the
+// event bus of its parent, when it is added to another runtime as a child.
+// The patterns are event names, or patterns as understood by path.Match,
+// such as "*". By default, no events are forwarded.
+func WithEventForwarding(patterns ...string) Option {
+	return func(r *Runtime) {
+		r.forwardEvents = patterns
+	}
+}
+
+// WithClock sets the clock used by the runtime for all timing. Defaults to
+// SystemClock.
+func WithClock(clock Clock) Option {
+	return func(r *Runtime) {
+		r.clock = clock
+	}
+}
+
+// WithNameConflictPolicy sets what happens when a service is added under a
+// name that is already taken. Defaults to NameConflictReject.
+func WithNameConflictPolicy(policy NameConflictPolicy) Option {
+	return func(r *Runtime) {
+		r.namingPolicy = policy
+	}
+}
+
+// RegistryHooks are called synchronously when a service is registered with,
+// or removed from, the runtime. Either hook may be nil.
+type RegistryHooks struct {
+	// OnAdd is called when a service is added, before it is initialized.
+	OnAdd func(service IsRuntimeService)
+
+	// OnRemove is called when a service has been removed.
+	OnRemove func(service IsRuntimeService)
+}
+
+// WithRegistryHooks adds hooks that are called when services are added to,
+// or removed from, the runtime. The option can be given several times.
+func WithRegistryHooks(hooks RegistryHooks) Option {
+	return func(r *Runtime) {
+		r.registryHooks = append(r.registryHooks, hooks)
+	}
+}
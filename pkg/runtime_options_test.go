@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// lockedBuffer is a bytes.Buffer that can be written to by several loggers
+// at once.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+type stuckService struct {
+	exampleService
+	release chan struct{}
+}
+
+func (s *stuckService) Name() string {
+	return "stuck"
+}
+
+func (s *stuckService) OnShutdown() {
+	<-s.release
+}
+
+func TestRuntime_Options(t *testing.T) {
+	var (
+		buf     lockedBuffer
+		added   []string
+		removed []string
+	)
+
+	bus := NewEventBus(8)
+
+	rt := New("options",
+		WithLogger(zerolog.New(&buf)),
+		WithoutSignalHandling(),
+		WithShutdownTimeout(time.Millisecond*50),
+		WithEventBus(bus),
+		WithRegistryHooks(RegistryHooks{
+			OnAdd:    func(s IsRuntimeService) { added = append(added, s.Name()) },
+			OnRemove: func(s IsRuntimeService) { removed = append(removed, s.Name()) },
+		}),
+	)
+
+	if rt.Events() != bus {
+		t.Error("expected the event bus option to apply")
+	}
+
+	service := &stuckService{release: make(chan struct{})}
+	defer close(service.release)
+
+	rt.Add(service).Wait()
+
+	if !strings.Contains(buf.String(), `"service":"options"`) {
+		t.Errorf("expected the runtime to log through the given logger, got %q", buf.String())
+	}
+
+	// the stuck service must not block shutdown beyond the timeout
+	done := make(chan struct{})
+
+	go func() {
+		rt.Shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("shutdown did not time out")
+	}
+
+	if !strings.Contains(buf.String(), `waiting on service \"stuck\"`) {
+		t.Errorf("expected the stuck service to be logged, got %q", buf.String())
+	}
+
+	rt.Remove(service)
+
+	if strings.Join(added, ",") != "options,stuck" {
+		t.Errorf("unexpected added services %v", added)
+	}
+
+	if strings.Join(removed, ",") != "stuck" {
+		t.Errorf("unexpected removed services %v", removed)
+	}
+
+}
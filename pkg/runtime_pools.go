@@ -0,0 +1,442 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned when submitting a job to a worker pool that
+	// is draining or drained.
+	ErrPoolClosed = errors.New("worker pool closed")
+
+	// ErrPoolFull is returned by TrySubmit when the queue of a worker pool
+	// is full.
+	ErrPoolFull = errors.New("worker pool full")
+)
+
+// WorkerPool runs the jobs submitted to it with a fixed number of workers,
+// queueing up to a bounded number of jobs. It is owned by the runtime, and
+// drained when the runtime shuts down.
+type WorkerPool struct {
+	name   string
+	owner  IsRuntimeService
+	report func(error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closing is closed when the pool starts draining, before the queue is,
+	// so that submitters waiting for room give up and release mu
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// mu guards the queue against being closed while a job is submitted
+	mu     sync.RWMutex
+	closed bool
+	queue  chan poolJob
+	wg     sync.WaitGroup
+
+	statsMu sync.Mutex
+	stats   WorkerPoolStats
+}
+
+type poolJob struct {
+	ctx context.Context
+	fn  func(ctx context.Context) error
+}
+
+// WorkerPoolStats holds the metrics of a worker pool.
+type WorkerPoolStats struct {
+	// Name is the name of the pool.
+	Name string
+
+	// Owner is the name of the service that requested the pool.
+	Owner string
+
+	// Size is the number of workers.
+	Size int
+
+	// QueueDepth is the number of jobs that can wait for a worker.
+	QueueDepth int
+
+	// Queued is the number of jobs waiting for a worker.
+	Queued int
+
+	// Active is the number of jobs being run.
+	Active int
+
+	// Submitted is the number of jobs accepted by the pool.
+	Submitted uint64
+
+	// Completed is the number of jobs that returned without an error.
+	Completed uint64
+
+	// Failed is the number of jobs that returned an error or panicked.
+	Failed uint64
+
+	// Panics is the number of jobs that panicked.
+	Panics uint64
+
+	// Rejected is the number of jobs that were not run, because the pool
+	// was full or closed, or their context was done before they started.
+	Rejected uint64
+}
+
+func newWorkerPool(name string, owner IsRuntimeService, size, queueDepth int, report func(error)) *WorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &WorkerPool{
+		name:    name,
+		owner:   owner,
+		report:  report,
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		queue:   make(chan poolJob, queueDepth),
+		stats: WorkerPoolStats{
+			Name:       name,
+			Size:       size,
+			QueueDepth: queueDepth,
+		},
+	}
+
+	p.wg.Add(size)
+
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// Name returns the name of the pool.
+func (p *WorkerPool) Name() string {
+	return p.name
+}
+
+// Submit queues a job, waiting for room in the queue while it is full, until
+// the context is done or the pool is drained. The context is also given to
+// the job, which is not run if the context is done before a worker picks it
+// up.
+func (p *WorkerPool) Submit(ctx context.Context, fn func(ctx context.Context) error) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.isClosing() {
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.queue <- poolJob{ctx: ctx, fn: fn}:
+		p.count(func(s *WorkerPoolStats) { s.Submitted++ })
+		return nil
+	case <-ctx.Done():
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return ctx.Err()
+	case <-p.closing:
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return ErrPoolClosed
+	}
+}
+
+// TrySubmit queues a job if there is room in the queue, and returns
+// ErrPoolFull otherwise.
+func (p *WorkerPool) TrySubmit(fn func(ctx context.Context) error) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.isClosing() {
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.queue <- poolJob{ctx: context.Background(), fn: fn}:
+		p.count(func(s *WorkerPoolStats) { s.Submitted++ })
+		return nil
+	default:
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return ErrPoolFull
+	}
+}
+
+// isClosing returns true once the pool started draining. The caller holds mu.
+func (p *WorkerPool) isClosing() bool {
+	select {
+	case <-p.closing:
+		return true
+	default:
+		return p.closed
+	}
+}
+
+// Stats returns the metrics of the pool.
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+
+	stats := p.stats
+	stats.Queued = len(p.queue)
+
+	return stats
+}
+
+func (p *WorkerPool) count(update func(s *WorkerPoolStats)) {
+	p.statsMu.Lock()
+	update(&p.stats)
+	p.statsMu.Unlock()
+}
+
+// work runs the queued jobs until the queue is closed.
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+
+	for job := range p.queue {
+		p.run(job)
+	}
+}
+
+// run runs a job with a context that is also cancelled when the pool is
+// stopped without draining in time.
+func (p *WorkerPool) run(job poolJob) {
+	if job.ctx.Err() != nil {
+		p.count(func(s *WorkerPoolStats) { s.Rejected++ })
+		return
+	}
+
+	ctx, cancel := context.WithCancel(job.ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	p.count(func(s *WorkerPoolStats) { s.Active++ })
+
+	err, panicked := p.call(ctx, job.fn)
+
+	p.count(func(s *WorkerPoolStats) {
+		s.Active--
+
+		switch {
+		case panicked:
+			s.Panics++
+			s.Failed++
+		case err != nil:
+			s.Failed++
+		default:
+			s.Completed++
+		}
+	})
+
+	if err != nil {
+		p.report(err)
+	}
+}
+
+// call calls the function of a job, recovering from a panic.
+func (p *WorkerPool) call(ctx context.Context, fn func(ctx context.Context) error) (err error, panicked bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err, panicked = fmt.Errorf("job panic: %v", recovered), true
+		}
+	}()
+
+	return fn(ctx), false
+}
+
+// drain stops accepting jobs, and waits for the queued ones to be run until
+// the timeout channel fires. It returns false if the jobs did not complete in
+// time, in which case their contexts are cancelled.
+func (p *WorkerPool) drain(timeout <-chan time.Time) bool {
+	p.closingOnce.Do(func() {
+		close(p.closing)
+	})
+
+	defer p.cancel()
+
+	done := make(chan struct{})
+
+	// closing the queue waits for the submissions in progress, so it is
+	// bounded by the timeout as well
+	go func() {
+		p.mu.Lock()
+
+		if !p.closed {
+			p.closed = true
+			close(p.queue)
+		}
+
+		p.mu.Unlock()
+
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-timeout:
+		return false
+	}
+}
+
+// WorkerPool returns the worker pool with the given name, creating it with
+// the given number of workers and queue depth if it does not exist yet. The
+// pool is owned by the service that requested it first, or by the runtime if
+// the owner is nil.
+//
+// When the runtime shuts down, the pools are drained before any service is
+// shut down, in dependency order: the pools of a service are drained before
+// those of the services it depends on, so that queued jobs can still use
+// them. The pools of a service that is removed are drained as well. The
+// drain of each pool is bounded by the task shutdown timeout, see
+// WithTaskShutdownTimeout.
+func (r *Runtime) WorkerPool(owner IsRuntimeService, name string, size, queueDepth int) *WorkerPool {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	if pool, found := r.pools[name]; found {
+		return pool
+	}
+
+	if owner == nil {
+		owner = r
+	}
+
+	pool := newWorkerPool(name, owner, size, queueDepth, func(err error) {
+		r.log().Error().Err(err).Msgf("job of worker pool %q failed", name)
+	})
+
+	pool.stats.Owner = r.names[owner]
+
+	r.pools[name] = pool
+
+	return pool
+}
+
+// PoolStats returns the metrics of the worker pools of the runtime, by name.
+func (r *Runtime) PoolStats() map[string]WorkerPoolStats {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	stats := make(map[string]WorkerPoolStats, len(r.pools))
+	for name, pool := range r.pools {
+		stats[name] = pool.Stats()
+	}
+
+	return stats
+}
+
+// drainPools drains the worker pools owned by the given services, those of
+// dependent services first, and forgets them so that the services request new
+// ones if they are initialized again.
+func (r *Runtime) drainPools(services []IsRuntimeService) {
+	r.servicesMu.Lock()
+
+	owned := make(map[IsRuntimeService][]*WorkerPool)
+
+	for name, pool := range r.pools {
+		for _, service := range services {
+			if pool.owner == service {
+				owned[service] = append(owned[service], pool)
+				delete(r.pools, name)
+			}
+		}
+	}
+
+	r.servicesMu.Unlock()
+
+	if len(owned) == 0 {
+		return
+	}
+
+	for _, service := range r.dependentsFirst(services) {
+		pools := owned[service]
+
+		sort.Slice(pools, func(i, j int) bool {
+			return pools[i].name < pools[j].name
+		})
+
+		for _, pool := range pools {
+			if !pool.drain(r.clock.After(r.taskShutdownTimeout)) {
+				r.ReportError(service, fmt.Errorf("worker pool %q did not drain within %s", pool.name, r.taskShutdownTimeout), false)
+			}
+		}
+	}
+}
+
+// dependentsFirst orders the services so that each one comes before the
+// services it depends on. Services that do not depend on each other keep
+// their order.
+func (r *Runtime) dependentsFirst(services []IsRuntimeService) []IsRuntimeService {
+	dependents := make(map[string][]string)
+
+	for _, edge := range r.DependencyGraph().Edges {
+		dependents[edge.To] = append(dependents[edge.To], edge.From)
+	}
+
+	return r.orderServices(services, dependents)
+}
+
+// dependenciesFirst orders the services so that each one comes after the
+// services it depends on. Services that do not depend on each other keep
+// their order.
+func (r *Runtime) dependenciesFirst(services []IsRuntimeService) []IsRuntimeService {
+	dependencies := make(map[string][]string)
+
+	for _, edge := range r.DependencyGraph().Edges {
+		dependencies[edge.From] = append(dependencies[edge.From], edge.To)
+	}
+
+	return r.orderServices(services, dependencies)
+}
+
+// orderServices orders the services so that the services each one is linked
+// to come before it.
+func (r *Runtime) orderServices(services []IsRuntimeService, links map[string][]string) []IsRuntimeService {
+	byName := make(map[string]IsRuntimeService, len(services))
+	for _, service := range services {
+		byName[r.ServiceName(service)] = service
+	}
+
+	ordered := make([]IsRuntimeService, 0, len(services))
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		visited[name] = true
+
+		for _, linked := range links[name] {
+			visit(linked)
+		}
+
+		if service, found := byName[name]; found {
+			ordered = append(ordered, service)
+		}
+	}
+
+	for _, service := range services {
+		visit(r.ServiceName(service))
+	}
+
+	return ordered
+}
@@ -0,0 +1,230 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	rt := New("pools", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+	defer rt.Shutdown()
+
+	pool := rt.WorkerPool(nil, "jobs", 1, 1)
+
+	if rt.WorkerPool(nil, "jobs", 5, 5) != pool {
+		t.Fatal("expected the pool to be shared by name")
+	}
+
+	release := make(chan struct{})
+	blocked := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+
+	// one job runs, the other waits in the queue
+	for i := 0; i < 2; i++ {
+		if err := pool.Submit(context.Background(), blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pool.Stats().Active != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := pool.TrySubmit(blocked); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("expected the pool to be full, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if err := pool.Submit(ctx, blocked); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the submission to wait for room until the deadline, got %v", err)
+	}
+
+	close(release)
+
+	_ = pool.Submit(context.Background(), func(ctx context.Context) error { panic("oops") })
+	_ = pool.Submit(context.Background(), func(ctx context.Context) error { return errors.New("failed") })
+
+	for pool.Stats().Completed+pool.Stats().Failed != 4 {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := pool.Stats()
+	if stats.Submitted != 4 || stats.Completed != 2 || stats.Failed != 2 || stats.Panics != 1 || stats.Rejected != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if stats.Owner != "pools" {
+		t.Errorf("expected the runtime to own the pool, got %q", stats.Owner)
+	}
+}
+
+func TestWorkerPool_DrainBlockedSubmitter(t *testing.T) {
+	pool := newWorkerPool("jobs", nil, 1, 1, func(error) {})
+
+	release := make(chan struct{})
+	blocked := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+
+	// one job runs, the other fills the queue
+	for i := 0; i < 2; i++ {
+		if err := pool.Submit(context.Background(), blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pool.Stats().Active != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	submitted := make(chan error, 1)
+
+	go func() {
+		submitted <- pool.Submit(context.Background(), blocked)
+	}()
+
+	// let the submitter block on the full queue
+	time.Sleep(time.Millisecond * 10)
+
+	drained := make(chan bool, 1)
+
+	go func() {
+		drained <- pool.drain(time.After(time.Second * 5))
+	}()
+
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("expected the blocked submission to be rejected, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the drain to release the blocked submitter")
+	}
+
+	close(release)
+
+	if !<-drained {
+		t.Error("expected the queued jobs to be drained")
+	}
+
+	if stats := pool.Stats(); stats.Completed != 2 || stats.Rejected != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWorkerPool_DrainTimeout(t *testing.T) {
+	pool := newWorkerPool("jobs", nil, 1, 0, func(error) {})
+
+	running := make(chan struct{})
+
+	_ = pool.Submit(context.Background(), func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	<-running
+
+	// the job ignores the drain until its context is cancelled by the timeout
+	if pool.drain(time.After(time.Millisecond * 10)) {
+		t.Error("expected the drain to time out")
+	}
+
+	if err := pool.TrySubmit(func(ctx context.Context) error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected the pool to be closed, got %v", err)
+	}
+}
+
+func TestRuntime_WorkerPoolDrainOrder(t *testing.T) {
+	rt := New("pools", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	db := &countingService{name: "db"}
+	api := &declaringService{countingService: countingService{name: "api"}, dependsOn: []string{"db"}}
+
+	rt.Add(db).Wait()
+	rt.Add(api).Wait()
+
+	dbPool := rt.WorkerPool(db, "db", 1, 4)
+	apiPool := rt.WorkerPool(api, "api", 1, 4)
+
+	gate := make(chan struct{})
+
+	// the queued jobs of the api still use the db while draining
+	_ = apiPool.Submit(context.Background(), func(ctx context.Context) error {
+		<-gate
+
+		if db.shutdowns.Load() > 0 || api.shutdowns.Load() > 0 {
+			return errors.New("services were shut down before the pools were drained")
+		}
+
+		return dbPool.TrySubmit(func(ctx context.Context) error { return nil })
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		rt.Shutdown().Wait()
+		close(done)
+	}()
+
+	for !errors.Is(apiPool.TrySubmit(func(ctx context.Context) error { return nil }), ErrPoolClosed) {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(gate)
+	<-done
+
+	if stats := apiPool.Stats(); stats.Failed != 0 || stats.Completed == 0 {
+		t.Errorf("expected the api pool to drain before the db pool, got %+v", stats)
+	}
+
+	if stats := dbPool.Stats(); stats.Completed != 1 {
+		t.Errorf("expected the db pool to run the job queued while draining, got %+v", stats)
+	}
+
+	if len(rt.PoolStats()) != 0 {
+		t.Error("expected the drained pools to be forgotten")
+	}
+}
+
+func TestRuntime_RemoveDrainsPools(t *testing.T) {
+	rt := New("pools", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	indexer := &countingService{name: "indexer"}
+	rt.Add(indexer).Wait()
+
+	pool := rt.WorkerPool(indexer, "indexing", 1, 4)
+
+	var ran atomic.Bool
+
+	if err := pool.TrySubmit(func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rt.Remove(indexer).Wait()
+
+	if !ran.Load() {
+		t.Error("expected the queued job to run before the service was removed")
+	}
+
+	if err := pool.TrySubmit(func(ctx context.Context) error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected the pool of the removed service to be closed, got %v", err)
+	}
+
+	if _, found := rt.PoolStats()["indexing"]; found {
+		t.Error("expected the pool of the removed service to be forgotten")
+	}
+
+	rt.Shutdown().Wait()
+}
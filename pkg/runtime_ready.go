@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+var (
+	// ErrStartupTimeout is the error of a runtime whose services were not
+	// ready within the startup timeout, see WithStartupTimeout.
+	ErrStartupTimeout = errors.New("startup timed out")
+
+	// ErrRuntimeStopped is returned by WaitReady when the runtime was shut
+	// down before it was ready.
+	ErrRuntimeStopped = errors.New("runtime stopped")
+)
+
+// Ready returns true once the runtime is ready: every service was initialized
+// and started by Run.
+func (r *Runtime) Ready() bool {
+	select {
+	case <-r.startDone:
+		return r.startErr == nil
+	default:
+		return false
+	}
+}
+
+// WaitReady blocks until the runtime is ready, and returns nil, or until it
+// fails to start, and returns why. It returns ErrRuntimeStopped if the runtime
+// is shut down before, and the error of the context if it is done before.
+func (r *Runtime) WaitReady(ctx context.Context) error {
+	select {
+	case <-r.startDone:
+		return r.startErr
+	case <-r.stopped:
+		return ErrRuntimeStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NotReady returns the names of the services that are not initialized yet,
+// or that have an OnStart hook that has not returned yet, sorted.
+func (r *Runtime) NotReady() []string {
+	names := make([]string, 0)
+
+	for _, service := range r.hookedServices() {
+		r.servicesMu.RLock()
+		state, started := r.states[service], r.startedServices[service]
+		r.servicesMu.RUnlock()
+
+		_, hooked := service.(HasStartHook)
+
+		if (hooked && !started) || (state != ServiceStateRunning && state != ServiceStateStopped) {
+			names = append(names, r.ServiceName(service))
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// startupTimeoutError describes the services that were not ready when the
+// startup timeout elapsed.
+func (r *Runtime) startupTimeoutError() error {
+	return fmt.Errorf("%w after %s, services not ready: %s", ErrStartupTimeout, r.startupTimeout, strings.Join(r.NotReady(), ", "))
+}
+
+// markStarted records that the OnStart hook of the service returned.
+func (r *Runtime) markStarted(service IsRuntimeService) {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	r.startedServices[service] = true
+}
+
+// finishStart records the outcome of the start of the runtime. The runtime is
+// ready if there is no error, which is only recorded once.
+func (r *Runtime) finishStart(err error) {
+	r.startOnce.Do(func() {
+		r.startErr = err
+
+		if err == nil {
+			r.log().Info().Msg("ready")
+			r.events.Emit(events.EventRuntimeReady)
+		}
+
+		close(r.startDone)
+	})
+}
@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// slowService is initialized once released.
+type slowService struct {
+	countingService
+	release chan struct{}
+}
+
+func (s *slowService) Init(_ IsRuntime) {
+	<-s.release
+}
+
+func TestRuntime_WaitReady(t *testing.T) {
+	rt := New("ready", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	slow := &slowService{countingService: countingService{name: "slow"}, release: make(chan struct{})}
+	rt.Add(slow)
+
+	go rt.Run()
+	defer rt.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	if err := rt.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the runtime not to be ready before the service is initialized, got %v", err)
+	}
+
+	if rt.Ready() || strings.Join(rt.NotReady(), ",") != "slow" {
+		t.Errorf("expected the slow service not to be ready, got %v", rt.NotReady())
+	}
+
+	close(slow.release)
+
+	if err := rt.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !rt.Ready() || len(rt.NotReady()) != 0 {
+		t.Errorf("expected every service to be ready, got %v", rt.NotReady())
+	}
+
+	for _, record := range rt.Events().History() {
+		if record.Name == events.EventRuntimeRunLoopInitiated {
+			t.Fatal("expected the ready event to be emitted before the run loop begins")
+		}
+
+		if record.Name == events.EventRuntimeReady {
+			return
+		}
+	}
+
+	t.Error("expected the ready event to be emitted")
+}
+
+func TestRuntime_StartupTimeout(t *testing.T) {
+	rt := New("ready", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}), WithStartupTimeout(time.Millisecond*50))
+
+	slow := &slowService{countingService: countingService{name: "slow"}, release: make(chan struct{})}
+	defer close(slow.release)
+
+	rt.Add(&countingService{name: "fast"})
+	rt.Add(slow)
+
+	done := make(chan struct{})
+
+	go func() {
+		rt.Run()
+		close(done)
+	}()
+
+	err := rt.WaitReady(context.Background())
+	if !errors.Is(err, ErrStartupTimeout) {
+		t.Fatalf("expected the startup to time out, got %v", err)
+	}
+
+	if !strings.HasSuffix(err.Error(), "services not ready: slow") {
+		t.Errorf("expected the error to list the services not ready, got %q", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the runtime to shut down")
+	}
+}
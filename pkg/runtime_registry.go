@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrServiceNotFound is returned when a service is not managed by the
+	// runtime.
+	ErrServiceNotFound = errors.New("service not found")
+
+	// ErrServiceExists is returned when a service is added to the runtime
+	// more than once.
+	ErrServiceExists = errors.New("service already added")
+
+	// ErrServiceNameTaken is returned when a service is added under a name
+	// that is taken, and the name conflict policy is NameConflictReject.
+	ErrServiceNameTaken = errors.New("service name taken")
+)
+
+// NameConflictPolicy decides what happens when a service is added under a
+// name that is already taken by another service.
+type NameConflictPolicy int
+
+const (
+	// NameConflictReject refuses to add the service. This is the default.
+	NameConflictReject NameConflictPolicy = iota
+
+	// NameConflictSuffix adds the service under the first free name made of
+	// the name and a number, eg "cache#2".
+	NameConflictSuffix
+
+	// NameConflictReplace replaces the service that holds the name, as
+	// with Runtime.Replace.
+	NameConflictReplace
+)
+
+// String returns the name of the policy.
+func (p NameConflictPolicy) String() string {
+	switch p {
+	case NameConflictSuffix:
+		return "suffix"
+	case NameConflictReplace:
+		return "replace"
+	default:
+		return "reject"
+	}
+}
+
+// ServiceName returns the name the service was added under, which is its own
+// name unless it was added with AddNamed or renamed by the name conflict
+// policy.
+func (r *Runtime) ServiceName(service IsRuntimeService) string {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	if name, found := r.names[service]; found {
+		return name
+	}
+
+	return service.Name()
+}
+
+// ServiceNames returns the names of all services, sorted.
+func (r *Runtime) ServiceNames() []string {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	names := make([]string, 0, len(r.names))
+	for _, name := range r.names {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Service looks up a service by the name it was added under.
+func (r *Runtime) Service(name string) (IsRuntimeService, bool) {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	service := r.holder(name)
+
+	return service, service != nil
+}
+
+// ServiceOfType returns the first service of type T, which is usually an
+// interface the service implements, or a pointer to its concrete type.
+func ServiceOfType[T any](rt IsRuntime) (T, bool) {
+	for _, service := range rt.Services() {
+		if candidate, ok := service.(T); ok {
+			recordLookup(rt, service)
+			return candidate, true
+		}
+	}
+
+	var zero T
+
+	return zero, false
+}
+
+// ServicesOfType returns every service of type T, in the order they were
+// added.
+func ServicesOfType[T any](rt IsRuntime) []T {
+	services := make([]T, 0)
+
+	for _, service := range rt.Services() {
+		if candidate, ok := service.(T); ok {
+			recordLookup(rt, service)
+			services = append(services, candidate)
+		}
+	}
+
+	return services
+}
+
+// register adds the service to the registry under the given name, applying
+// the name conflict policy. It returns the name the service was registered
+// under, or the service to replace with it, which is not registered yet.
+func (r *Runtime) register(name string, service IsRuntimeService) (string, IsRuntimeService, error) {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	if _, found := r.names[service]; found {
+		return name, nil, ErrServiceExists
+	}
+
+	if holder := r.holder(name); holder != nil {
+		switch r.namingPolicy {
+		case NameConflictReplace:
+			return name, holder, nil
+		case NameConflictSuffix:
+			base := name
+			for i := 2; r.holder(name) != nil; i++ {
+				name = fmt.Sprintf("%s#%d", base, i)
+			}
+		default:
+			return name, nil, fmt.Errorf("%w: %q", ErrServiceNameTaken, name)
+		}
+	}
+
+	r.names[service] = name
+	r.states[service] = ServiceStatePending
+	r.services = append(r.services, service)
+
+	return name, nil, nil
+}
+
+// holder returns the service registered under the name, or nil. The caller
+// holds servicesMu.
+func (r *Runtime) holder(name string) IsRuntimeService {
+	for _, service := range r.services {
+		if r.names[service] == name {
+			return service
+		}
+	}
+
+	return nil
+}
+
+// instanceName is the name a service is registered under, for the loggers
+// of the service.
+type instanceName string
+
+func (n instanceName) Name() string {
+	return string(n)
+}
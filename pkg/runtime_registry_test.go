@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestRuntime_NameConflictPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy NameConflictPolicy
+		names  []string
+	}{
+		{NameConflictReject, []string{"cache", "reject"}},
+		{NameConflictSuffix, []string{"cache", "cache#2", "suffix"}},
+		{NameConflictReplace, []string{"cache", "replace"}},
+	} {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			rt := New(test.policy.String(), WithoutSignalHandling(), WithNameConflictPolicy(test.policy))
+
+			first := &countingService{name: "cache"}
+			second := &countingService{name: "cache"}
+
+			rt.Add(first).Wait()
+			rt.Add(second).Wait()
+
+			names := rt.ServiceNames()
+			if len(names) != len(test.names) {
+				t.Fatalf("expected services %v, got %v", test.names, names)
+			}
+
+			for i := range names {
+				if names[i] != test.names[i] {
+					t.Fatalf("expected services %v, got %v", test.names, names)
+				}
+			}
+
+			holder, _ := rt.Service("cache")
+
+			if test.policy == NameConflictReplace && holder != second {
+				t.Error("expected the second service to replace the first")
+			}
+
+			if test.policy != NameConflictReplace && holder != first {
+				t.Error("expected the first service to keep its name")
+			}
+		})
+	}
+}
+
+func TestRuntime_NamedInstances(t *testing.T) {
+	rt := New("instances", WithoutSignalHandling())
+
+	users := &countingService{name: "cache"}
+	sessions := &countingService{name: "cache"}
+
+	rt.AddNamed("cache/users", users).Wait()
+	rt.AddNamed("cache/sessions", sessions).Wait()
+
+	if found, ok := rt.Service("cache/sessions"); !ok || found != sessions {
+		t.Error("expected to look up the instance by name")
+	}
+
+	if name := rt.ServiceName(users); name != "cache/users" {
+		t.Errorf("unexpected instance name %q", name)
+	}
+
+	if caches := ServicesOfType[*countingService](rt); len(caches) != 2 {
+		t.Errorf("expected both instances by type, got %d", len(caches))
+	}
+
+	if _, ok := ServiceOfType[*stuckService](rt); ok {
+		t.Error("expected no service of another type")
+	}
+
+	rt.Remove(users)
+
+	if _, ok := rt.Service("cache/users"); ok {
+		t.Error("expected the name to be released by Remove")
+	}
+}
@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+// Replace swaps a service for another without stopping the runtime, eg to
+// roll out a new implementation of it, see ReplaceContext. The dependencies
+// of the replacement must be resolved within the startup timeout of the
+// runtime, if it has one, see WithStartupTimeout.
+func (r *Runtime) Replace(old, replacement IsRuntimeService) error {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	if r.startupTimeout > 0 {
+		deadline := r.clock.After(r.startupTimeout)
+
+		go func() {
+			select {
+			case <-deadline:
+				cancel(fmt.Errorf("%w after %s, dependencies not resolved", ErrStartupTimeout, r.startupTimeout))
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return r.ReplaceContext(ctx, old, replacement)
+}
+
+// ReplaceContext swaps a service for another without stopping the runtime.
+//
+// The replacement is bound, has its dependencies resolved and is initialized
+// while the old service keeps running. If the context is done before the
+// dependencies are resolved, the replacement is dropped, the old service
+// keeps its place, and the error of the context is returned. A dropped
+// replacement no longer handles the events of the runtime.
+//
+// Otherwise, the replacement takes the place of the old service in the
+// registry, along with its request handlers, and the "service replaced" event
+// is emitted with the old and the new service. If the runtime is ready, the
+// OnAllInitialized and OnStart hooks of the replacement are called before it
+// takes the place of the old service, and it is dropped if OnStart fails.
+//
+// The services that keep references to the old service re-point at the
+// replacement when they handle the event, by implementing
+// EventHandlerServiceReplaced, or look their dependencies up by name with a
+// Lazy handle. Finally, once every handler of the event has returned, the old
+// service no longer handles the events of the runtime, and its OnStop,
+// OnShutdown and OnStopped hooks are called.
+func (r *Runtime) ReplaceContext(ctx context.Context, old, replacement IsRuntimeService) error {
+	if old == r || replacement == r {
+		return errors.New("the runtime cannot replace itself")
+	}
+
+	r.servicesMu.Lock()
+
+	name, found := r.names[old]
+	_, taken := r.names[replacement]
+
+	if found && !taken {
+		// the replacement takes the name of the old service, so that it
+		// is bound the same logger settings
+		r.names[replacement] = name
+	}
+
+	r.servicesMu.Unlock()
+
+	if !found {
+		return fmt.Errorf("replacing %q: %w", old.Name(), ErrServiceNotFound)
+	}
+
+	if taken {
+		return fmt.Errorf("replacing %q: %w", name, ErrServiceExists)
+	}
+
+	r.log().Info().Msgf("replacing service %q with %q", name, replacement.Name())
+
+	if child, isRuntime := replacement.(*Runtime); !isRuntime || child == r {
+		r.bindEventHandlerInterfaces(replacement)
+	}
+
+	if r.bindLogger(replacement) {
+		r.events.Emit(events.EventServiceLoggerBound, replacement).Wait()
+	}
+
+	r.bindClock(replacement)
+
+	if err := r.startService(ctx, replacement); err != nil {
+		r.forget(replacement)
+
+		return fmt.Errorf("replacing %q: resolving the dependencies of %q: %w", name, replacement.Name(), err)
+	}
+
+	// the replacement is started while the old service keeps running
+	if err := r.startLate(ctx, []IsRuntimeService{replacement}); err != nil {
+		r.shutdownServices([]IsRuntimeService{replacement})
+		r.forget(replacement)
+
+		return fmt.Errorf("replacing %q: %w", name, err)
+	}
+
+	r.servicesMu.Lock()
+
+	swapped := false
+
+	for i, svc := range r.services {
+		if svc == old {
+			r.services[i] = replacement
+			delete(r.names, old)
+			delete(r.states, old)
+			delete(r.lookups, old)
+			delete(r.lazies, old)
+			swapped = true
+			break
+		}
+	}
+
+	r.servicesMu.Unlock()
+
+	if !swapped {
+		r.forget(replacement)
+
+		return fmt.Errorf("replacing %q: %w", name, ErrServiceNotFound)
+	}
+
+	r.unbindRequestHandlers(old)
+	r.bindRequestHandlers(replacement)
+
+	for _, hooks := range r.registryHooks {
+		if hooks.OnRemove != nil {
+			hooks.OnRemove(old)
+		}
+
+		if hooks.OnAdd != nil {
+			hooks.OnAdd(replacement)
+		}
+	}
+
+	r.events.Emit(events.EventServiceReplaced, old, replacement).Wait()
+
+	// the old service no longer handles the events of the runtime
+	r.unbindEventHandlers(old)
+
+	if child, ok := old.(*Runtime); ok {
+		child.detach(r)
+	}
+
+	r.stopLate([]IsRuntimeService{old})
+	r.shutdownServices([]IsRuntimeService{old})
+	r.servicesStopped([]IsRuntimeService{old})
+
+	return nil
+}
+
+// forget drops what the runtime knows about a replacement that did not take
+// the place of the service it was to replace.
+func (r *Runtime) forget(replacement IsRuntimeService) {
+	r.unbindEventHandlers(replacement)
+
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	delete(r.names, replacement)
+	delete(r.states, replacement)
+	delete(r.startedServices, replacement)
+	delete(r.lookups, replacement)
+	delete(r.lazies, replacement)
+}
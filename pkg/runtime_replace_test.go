@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type dependentService struct {
+	exampleService
+	dependency *countingService
+	replaced   chan IsRuntimeService
+}
+
+func (s *dependentService) Name() string {
+	return "dependent"
+}
+
+func (s *dependentService) DependenciesResolved() bool {
+	return s.dependency != nil
+}
+
+func (s *dependentService) ResolveDependencies(rt IsRuntime) {
+	if dependency, found := ServiceOfType[*countingService](rt); found {
+		s.dependency = dependency
+	}
+}
+
+func (s *dependentService) OnServiceReplaced(args ...any) {
+	// re-point at the replacement of the dependency
+	if replacement, ok := args[1].(*countingService); ok && args[0] == s.dependency {
+		s.dependency = replacement
+	}
+
+	s.replaced <- args[1].(IsRuntimeService)
+}
+
+func TestRuntime_Replace(t *testing.T) {
+	rt := New("replace", WithoutSignalHandling())
+
+	old := &countingService{name: "v1"}
+	dependent := &dependentService{replaced: make(chan IsRuntimeService, 1)}
+
+	rt.Add(old).Wait()
+	rt.Add(dependent).Wait()
+
+	replacement := &countingService{name: "v2"}
+
+	if err := rt.Replace(old, replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	if replacement.inits.Load() != 1 {
+		t.Error("expected the replacement to be initialized")
+	}
+
+	if old.shutdowns.Load() != 1 {
+		t.Error("expected the old service to be shut down")
+	}
+
+	if dependent.dependency != replacement {
+		t.Error("expected the dependent service to re-point at the replacement")
+	}
+
+	if got := <-dependent.replaced; got != replacement {
+		t.Errorf("unexpected replacement in event %v", got)
+	}
+
+	for _, service := range rt.Services() {
+		if service == old {
+			t.Error("expected the old service to be removed")
+		}
+	}
+
+	if err := rt.Replace(old, replacement); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("expected ErrServiceNotFound, got %v", err)
+	}
+
+	// a replacement whose dependencies are not resolved in time is dropped
+	stuck := &declaringService{countingService: countingService{name: "v3"}, dependsOn: []string{"missing"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if err := rt.ReplaceContext(ctx, replacement, stuck); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the replacement to time out, got %v", err)
+	}
+
+	if holder, _ := rt.Service("v1"); holder != replacement || replacement.shutdowns.Load() != 0 {
+		t.Error("expected the service to keep its place")
+	}
+
+	if names := rt.ServiceNames(); stuck.inits.Load() != 0 || len(names) != 3 {
+		t.Errorf("expected the replacement to be dropped, got services %v", names)
+	}
+}
+
+// watchingService counts the services added after it.
+type watchingService struct {
+	declaringService
+	added atomic.Int32
+}
+
+func (s *watchingService) OnServiceAdded(...any) {
+	s.added.Add(1)
+}
+
+func TestRuntime_ReplaceUnbindsEventHandlers(t *testing.T) {
+	rt := New("replace", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}))
+
+	old := &watchingService{declaringService: declaringService{countingService: countingService{name: "watcher"}}}
+	rt.Add(old).Wait()
+
+	replacement := &watchingService{declaringService: declaringService{countingService: countingService{name: "watcher v2"}}}
+
+	if err := rt.Replace(old, replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	// a replacement that is dropped
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	dropped := &watchingService{declaringService: declaringService{countingService: countingService{name: "watcher v3"}, dependsOn: []string{"missing"}}}
+
+	if err := rt.ReplaceContext(ctx, replacement, dropped); err == nil {
+		t.Fatal("expected the replacement to be dropped")
+	}
+
+	before := old.added.Load()
+
+	rt.Add(&countingService{name: "late"}).Wait()
+
+	if old.added.Load() != before {
+		t.Error("expected the replaced service not to handle events any more")
+	}
+
+	if dropped.added.Load() != 0 {
+		t.Error("expected the dropped replacement not to handle events")
+	}
+
+	if replacement.added.Load() != 1 {
+		t.Errorf("expected the replacement to handle events, got %d", replacement.added.Load())
+	}
+}
@@ -0,0 +1,63 @@
+package pkg
+
+// ServiceState is where a service is in its lifecycle.
+type ServiceState int
+
+const (
+	// ServiceStatePending is the state of a service that was added, but
+	// whose dependencies are not being resolved yet.
+	ServiceStatePending ServiceState = iota
+
+	// ServiceStateResolving is the state of a service that waits for its
+	// dependencies to be resolved.
+	ServiceStateResolving
+
+	// ServiceStateInitializing is the state of a service during its Init.
+	ServiceStateInitializing
+
+	// ServiceStateRunning is the state of a service once it is initialized.
+	ServiceStateRunning
+
+	// ServiceStateStopped is the state of a service that was shut down.
+	ServiceStateStopped
+)
+
+// String returns the name of the state.
+func (s ServiceState) String() string {
+	switch s {
+	case ServiceStateResolving:
+		return "resolving"
+	case ServiceStateInitializing:
+		return "initializing"
+	case ServiceStateRunning:
+		return "running"
+	case ServiceStateStopped:
+		return "stopped"
+	default:
+		return "pending"
+	}
+}
+
+// MarshalText encodes the state as its name, eg in JSON.
+func (s ServiceState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// StateOf returns the state of the service. Services that are not managed by
+// the runtime are reported as pending.
+func (r *Runtime) StateOf(service IsRuntimeService) ServiceState {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	return r.states[service]
+}
+
+// setState sets the state of a service, if it is managed by the runtime.
+func (r *Runtime) setState(service IsRuntimeService, state ServiceState) {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	if _, registered := r.names[service]; registered {
+		r.states[service] = state
+	}
+}
@@ -0,0 +1,345 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTaskShutdownTimeout is how long the runtime waits for the tasks of a
+// service to return once they are cancelled, unless set with
+// WithTaskShutdownTimeout.
+const DefaultTaskShutdownTimeout = time.Second * 5
+
+// HasTaskGroup is an optional interface for services that run goroutines.
+//
+// The runtime binds a new task group to the service before each Init. The
+// tasks started with it are cancelled when the service is shut down, after
+// its OnShutdown, or removed, and awaited with a timeout, so that no
+// goroutine of the service outlives it.
+type HasTaskGroup interface {
+	IsRuntimeService
+
+	// BindTaskGroup sets the task group of the service.
+	BindTaskGroup(tasks *TaskGroup)
+}
+
+// TaskGroup runs the goroutines of a service, and cancels them when the
+// service is shut down.
+type TaskGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	report func(error)
+
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[*task]struct{}
+}
+
+// task is a goroutine of a task group.
+type task struct {
+	goroutine uint64
+}
+
+func newTaskGroup(report func(error)) *TaskGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &TaskGroup{
+		ctx:     ctx,
+		cancel:  cancel,
+		report:  report,
+		running: make(map[*task]struct{}),
+	}
+}
+
+// Go runs fn in a new goroutine. The context given to fn is cancelled when
+// the service is shut down, and fn is expected to return then. An error
+// returned by fn, or a panic, is logged by the runtime.
+func (g *TaskGroup) Go(fn func(ctx context.Context) error) {
+	t := &task{}
+
+	g.wg.Add(1)
+
+	g.mu.Lock()
+	g.running[t] = struct{}{}
+	g.mu.Unlock()
+
+	go func() {
+		defer g.wg.Done()
+
+		g.mu.Lock()
+		t.goroutine = goroutineID()
+		g.mu.Unlock()
+
+		defer func() {
+			g.mu.Lock()
+			delete(g.running, t)
+			g.mu.Unlock()
+		}()
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				g.report(fmt.Errorf("task panic: %v", recovered))
+			}
+		}()
+
+		if err := fn(g.ctx); err != nil && g.ctx.Err() == nil {
+			g.report(err)
+		}
+	}()
+}
+
+// Context returns the context of the task group, which is cancelled when the
+// service is shut down.
+func (g *TaskGroup) Context() context.Context {
+	return g.ctx
+}
+
+// Running returns the number of tasks that have not returned yet.
+func (g *TaskGroup) Running() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.running)
+}
+
+// stop cancels the tasks and waits for them to return until the timeout
+// channel fires. It returns the goroutine IDs of the tasks that did not.
+func (g *TaskGroup) stop(timeout <-chan time.Time) []uint64 {
+	g.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout:
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	leaked := make([]uint64, 0, len(g.running))
+	for t := range g.running {
+		leaked = append(leaked, t.goroutine)
+	}
+
+	return leaked
+}
+
+// GoroutineLeak reports the goroutines of a service that were still running
+// after it was shut down.
+type GoroutineLeak struct {
+	// Service is the name of the service.
+	Service string
+
+	// Stacks are the stack traces of the goroutines.
+	Stacks []string
+}
+
+// Tasks returns the task group bound to the service, or nil if the service
+// does not implement HasTaskGroup or was not initialized yet.
+func (r *Runtime) Tasks(service IsRuntimeService) *TaskGroup {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	return r.tasks[service]
+}
+
+// Leaks returns the goroutine leaks found so far, when leak detection is
+// enabled with WithLeakDetection.
+func (r *Runtime) Leaks() []GoroutineLeak {
+	r.servicesMu.RLock()
+	defer r.servicesMu.RUnlock()
+
+	return append([]GoroutineLeak{}, r.leaks...)
+}
+
+// bindTaskGroup binds a new task group to the service, if it runs tasks, and
+// takes note of the running goroutines if leak detection is enabled.
+func (r *Runtime) bindTaskGroup(service IsRuntimeService) {
+	var before map[uint64]string
+	if r.detectLeaks {
+		before = goroutineStacks()
+	}
+
+	var group *TaskGroup
+
+	if candidate, ok := service.(HasTaskGroup); ok {
+		group = newTaskGroup(func(err error) {
+			r.ReportError(service, fmt.Errorf("task failed: %w", err), false)
+		})
+
+		candidate.BindTaskGroup(group)
+	}
+
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+
+	if group != nil {
+		r.tasks[service] = group
+	}
+
+	if before != nil {
+		r.goroutines[service] = before
+	}
+}
+
+// stopTasks cancels the tasks of a service that was shut down and waits for
+// them. With leak detection enabled, it reports the goroutines of the service
+// that are still running.
+func (r *Runtime) stopTasks(service IsRuntimeService) {
+	r.servicesMu.Lock()
+	group := r.tasks[service]
+	before := r.goroutines[service]
+	delete(r.tasks, service)
+	delete(r.goroutines, service)
+	r.servicesMu.Unlock()
+
+	name := r.ServiceName(service)
+
+	leaked := make([]uint64, 0)
+
+	if group != nil {
+		leaked = group.stop(r.clock.After(r.taskShutdownTimeout))
+
+		if len(leaked) > 0 {
+			r.ReportError(service, fmt.Errorf("%d tasks did not return within %s", len(leaked), r.taskShutdownTimeout), false)
+		}
+	}
+
+	if !r.detectLeaks {
+		return
+	}
+
+	stacks := leakedGoroutines(r.clock, service, before, leaked)
+	if len(stacks) == 0 {
+		return
+	}
+
+	r.servicesMu.Lock()
+	r.leaks = append(r.leaks, GoroutineLeak{Service: name, Stacks: stacks})
+	r.servicesMu.Unlock()
+
+	r.log().Error().Msgf("service %q leaked %d goroutines:\n\n%s", name, len(stacks), strings.Join(stacks, "\n\n"))
+}
+
+// leakedGoroutines returns the stacks of the given tasks that are still
+// running, and of the goroutines started by the service itself since it was
+// initialized, which are found by the function that created them. Those are
+// given a moment on the clock to return, as they are not awaited like tasks.
+func leakedGoroutines(clock Clock, service IsRuntimeService, before map[uint64]string, tasks []uint64) []string {
+	creator := serviceCreatorPrefix(service)
+
+	var stacks []string
+
+	for attempt := 0; attempt < 10; attempt++ {
+		stacks = stacks[:0]
+
+		for id, stack := range goroutineStacks() {
+			if _, existed := before[id]; existed && !containsID(tasks, id) {
+				continue
+			}
+
+			if containsID(tasks, id) || (creator != "" && strings.Contains(stack, "created by "+creator)) {
+				stacks = append(stacks, stack)
+			}
+		}
+
+		if len(stacks) == 0 {
+			break
+		}
+
+		clock.Sleep(time.Millisecond * 10)
+	}
+
+	return stacks
+}
+
+// serviceCreatorPrefix returns how the methods of the service are named in
+// stack traces, eg "example.com/app.(*Cache)".
+func serviceCreatorPrefix(service IsRuntimeService) string {
+	t := reflect.TypeOf(service)
+
+	pointer := t.Kind() == reflect.Pointer
+	if pointer {
+		t = t.Elem()
+	}
+
+	if t.PkgPath() == "" || t.Name() == "" {
+		return ""
+	}
+
+	if pointer {
+		return fmt.Sprintf("%s.(*%s)", t.PkgPath(), t.Name())
+	}
+
+	return fmt.Sprintf("%s.%s", t.PkgPath(), t.Name())
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// goroutineID returns the ID of the calling goroutine, as shown in stack
+// traces.
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// the trace starts with "goroutine <id> [running]:"
+	fields := strings.Fields(string(buf))
+	if len(fields) < 2 {
+		return 0
+	}
+
+	id, _ := strconv.ParseUint(fields[1], 10, 64)
+
+	return id
+}
+
+// goroutineStacks returns the stack traces of all goroutines, by ID.
+func goroutineStacks() map[uint64]string {
+	buf := make([]byte, 1<<16)
+
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+
+		buf = make([]byte, len(buf)*2)
+	}
+
+	stacks := make(map[uint64]string)
+
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		fields := strings.Fields(stack)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+
+		if id, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stacks[id] = stack
+		}
+	}
+
+	return stacks
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type taskService struct {
+	countingService
+	tasks   *TaskGroup
+	release chan struct{}
+}
+
+func (s *taskService) BindTaskGroup(tasks *TaskGroup) {
+	s.tasks = tasks
+}
+
+func (s *taskService) Init(_ IsRuntime) {
+	// returns when cancelled
+	s.tasks.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	s.tasks.Go(func(ctx context.Context) error {
+		return errors.New("task failed")
+	})
+
+	// ignores cancellation
+	s.tasks.Go(func(ctx context.Context) error {
+		<-s.release
+		return nil
+	})
+
+	// not a task at all
+	go func() {
+		<-s.release
+	}()
+}
+
+func TestRuntime_TaskGroups(t *testing.T) {
+	var buf lockedBuffer
+
+	rt := New("tasks", WithoutSignalHandling(), WithLogDestination(&buf), WithLeakDetection(), WithTaskShutdownTimeout(time.Millisecond*50))
+
+	service := &taskService{countingService: countingService{name: "worker"}, release: make(chan struct{})}
+	defer close(service.release)
+
+	rt.Add(service).Wait()
+
+	if rt.Tasks(service) != service.tasks {
+		t.Error("expected the task group to be bound")
+	}
+
+	rt.Shutdown().Wait()
+
+	if service.tasks.Context().Err() == nil {
+		t.Error("expected the tasks to be cancelled on shutdown")
+	}
+
+	if running := service.tasks.Running(); running != 1 {
+		t.Errorf("expected only the task that ignores cancellation to be running, got %d", running)
+	}
+
+	leaks := rt.Leaks()
+	if len(leaks) != 1 || leaks[0].Service != "worker" {
+		t.Fatalf("expected a leak of the worker, got %+v", leaks)
+	}
+
+	if len(leaks[0].Stacks) != 2 {
+		t.Errorf("expected the task and the goroutine to leak, got:\n%s", strings.Join(leaks[0].Stacks, "\n\n"))
+	}
+
+	if !strings.Contains(buf.String(), "task failed") {
+		t.Error("expected the error of the task to be logged")
+	}
+}
+
+func TestRuntime_RemoveCancelsTasks(t *testing.T) {
+	rt := New("tasks", WithoutSignalHandling(), WithLogDestination(&lockedBuffer{}), WithTaskShutdownTimeout(time.Millisecond*50))
+
+	service := &taskService{countingService: countingService{name: "worker"}, release: make(chan struct{})}
+	close(service.release)
+
+	rt.Add(service).Wait()
+	rt.Remove(service).Wait()
+
+	if service.tasks.Context().Err() == nil {
+		t.Error("expected the tasks to be cancelled when the service is removed")
+	}
+
+	if running := service.tasks.Running(); running != 0 {
+		t.Errorf("expected the tasks to have returned, got %d running", running)
+	}
+
+	rt.Shutdown().Wait()
+}
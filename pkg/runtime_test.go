@@ -1,6 +1,8 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -8,7 +10,7 @@ import (
 )
 
 func TestRuntime(t *testing.T) {
-	rt := New()
+	rt := New("test")
 
 	go func() {
 		time.Sleep(time.Second * 3)
@@ -44,3 +46,42 @@ func (e *exampleService) OnShutdown() {
 	time.Sleep(time.Second * 3)
 	e.logger.Info().Msg("graceful shutdown completed")
 }
+
+type responderService struct {
+	exampleService
+	name string
+}
+
+func (s *responderService) Name() string {
+	return s.name
+}
+
+func (s *responderService) RequestHandlers() map[string]RequestHandler {
+	return map[string]RequestHandler{
+		"whoami": func(context.Context, any) (any, error) {
+			return s.name, nil
+		},
+	}
+}
+
+func TestRuntime_RequestHandlers(t *testing.T) {
+	rt := New("requests", WithoutSignalHandling())
+
+	owner, other := &responderService{name: "owner"}, &responderService{name: "other"}
+
+	rt.Add(owner).Wait()
+	rt.Add(other).Wait() // its handler is rejected, the name is taken
+
+	// removing a service that did not register the handler keeps it
+	rt.Remove(other).Wait()
+
+	if got, err := rt.Events().Request(context.Background(), "whoami", nil); err != nil || got != "owner" {
+		t.Fatalf("expected the handler of the owner to answer, got %v, %v", got, err)
+	}
+
+	rt.Remove(owner).Wait()
+
+	if _, err := rt.Events().Request(context.Background(), "whoami", nil); !errors.Is(err, ErrNoRequestHandler) {
+		t.Errorf("expected the handler to be removed with its owner, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package runtimetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+var _ pkg.Clock = &FakeClock{}
+
+// FakeClock is a pkg.Clock whose time only moves when it is advanced, so that
+// tests of timeouts, retries and schedules are fast and deterministic. Give
+// it to a runtime with pkg.WithClock.
+//
+// Sleeping on a fake clock blocks until the clock is advanced past the end of
+// the sleep, so tests usually wait for the runtime or the service to sleep
+// with BlockUntil, and then Advance the clock.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+// fakeTimer fires at a point in time of a FakeClock, once, or at every period
+// for tickers.
+type fakeTimer struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewFakeClock creates a fake clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Sleep blocks until the clock is advanced by the duration.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// After returns a channel on which the time is sent once the clock is
+// advanced by the duration.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0).c
+}
+
+// NewTicker returns a ticker that ticks every time the clock is advanced by
+// the period.
+func (c *FakeClock) NewTicker(d time.Duration) pkg.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return c.add(d, d)
+}
+
+// Advance moves the time of the clock forward, firing the timers and tickers
+// that are due, in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.setLocked(c.now.Add(d))
+	c.mu.Unlock()
+}
+
+// Set moves the time of the clock to the given time, firing the timers and
+// tickers that are due, in order.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	c.setLocked(now)
+	c.mu.Unlock()
+}
+
+// Waiters returns the number of timers and tickers waiting on the clock.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting on the
+// clock, eg until a service is sleeping.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+func (c *FakeClock) add(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+
+	if d <= 0 {
+		timer.c <- c.now
+		return timer
+	}
+
+	c.timers = append(c.timers, timer)
+	c.changed.Broadcast()
+
+	return timer
+}
+
+func (c *FakeClock) setLocked(now time.Time) {
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+
+		if len(c.timers) == 0 || c.timers[0].at.After(now) {
+			break
+		}
+
+		timer := c.timers[0]
+		c.now = timer.at
+
+		// like those of the time package, ticks are dropped when the
+		// receiver is not keeping up
+		select {
+		case timer.c <- c.now:
+		default:
+		}
+
+		if timer.period > 0 {
+			timer.at = timer.at.Add(timer.period)
+		} else {
+			c.timers = c.timers[1:]
+		}
+	}
+
+	c.now = now
+	c.changed.Broadcast()
+}
+
+func (t *fakeTimer) remove() {
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i:i], t.clock.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.remove()
+	t.at = t.clock.now.Add(d)
+	t.period = d
+	t.clock.timers = append(t.clock.timers, t)
+	t.clock.changed.Broadcast()
+}
+
+func (t *fakeTimer) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.remove()
+	t.clock.changed.Broadcast()
+}
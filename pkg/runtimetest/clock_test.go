@@ -0,0 +1,127 @@
+package runtimetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+type waitingService struct {
+	Fake
+}
+
+func (s *waitingService) OptionalDependsOn() []string {
+	return []string{"missing"}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	after := clock.After(time.Second)
+	ticker := clock.NewTicker(time.Minute)
+
+	clock.Advance(time.Second)
+
+	if got := <-after; !got.Equal(start.Add(time.Second)) {
+		t.Errorf("unexpected time %s", got)
+	}
+
+	clock.Advance(time.Minute)
+
+	if got := <-ticker.C(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("unexpected tick %s", got)
+	}
+
+	ticker.Stop()
+
+	if waiters := clock.Waiters(); waiters != 0 {
+		t.Errorf("expected no waiters, got %d", waiters)
+	}
+}
+
+func TestFakeClock_Runtime(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rt := New(t, pkg.WithClock(clock), pkg.WithOptionalDependencyTimeout(time.Minute))
+
+	service := &waitingService{Fake: Fake{ServiceName: "waiting"}}
+	rt.Add(service)
+
+	// the resolution loop sleeps on the clock while it waits
+	clock.BlockUntil(1)
+
+	if state := rt.StateOf(service); state != pkg.ServiceStateResolving {
+		t.Fatalf("expected the service to wait for its optional dependency, got %s", state)
+	}
+
+	// the loop wakes up after the grace period, and gives up on waiting
+	clock.Advance(time.Minute + time.Second)
+
+	WaitForState(t, rt, service, pkg.ServiceStateRunning)
+
+	if service.Inits() != 1 {
+		t.Error("expected the service to be initialized once the grace period elapsed")
+	}
+}
+
+func TestFakeClock_Timestamps(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	rt := New(t, pkg.WithClock(clock))
+
+	Add(t, rt, NewFake("fake"))
+
+	for _, record := range rt.Events().History() {
+		if !record.Time.Equal(start) {
+			t.Errorf("expected event %q to be recorded at %s, got %s", record.Name, start, record.Time)
+		}
+	}
+
+	logs := rt.Logs(pkg.LogQuery{})
+	if len(logs) == 0 {
+		t.Fatal("expected the runtime to log")
+	}
+
+	for _, record := range logs {
+		if !record.Time.Equal(start) {
+			t.Errorf("expected %q to be captured at %s, got %s", record.Message, start, record.Time)
+		}
+	}
+}
+
+func TestFakeClock_RequestTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	rt := New(t, pkg.WithClock(clock))
+
+	err := rt.Events().HandleRequest("slow", func(ctx context.Context, _ any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waiters := clock.Waiters()
+	errs := make(chan error, 1)
+
+	go func() {
+		_, err := rt.Events().Request(context.Background(), "slow", nil)
+		errs <- err
+	}()
+
+	// the request waits for the default timeout on the clock
+	clock.BlockUntil(waiters + 1)
+	clock.Advance(pkg.DefaultRequestTimeout)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the request to time out, got %v", err)
+		}
+	case <-time.After(DefaultTimeout):
+		t.Fatal("expected the request to time out once the clock was advanced")
+	}
+}
@@ -0,0 +1,75 @@
+package runtimetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+// Emitted returns the retained records of the event, oldest first.
+func Emitted(rt pkg.IsRuntime, event string) []pkg.EventRecord {
+	records := make([]pkg.EventRecord, 0)
+
+	for _, record := range rt.Events().History() {
+		if record.Name == event {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// AssertEmitted fails the test if the event was not emitted with arguments
+// accepted by match. A nil match accepts any arguments.
+func AssertEmitted(t testing.TB, rt pkg.IsRuntime, event string, match func(args ...any) bool) {
+	t.Helper()
+
+	for _, record := range Emitted(rt, event) {
+		if match == nil || match(record.Args...) {
+			return
+		}
+	}
+
+	t.Errorf("expected event %q to be emitted", event)
+}
+
+// AssertNotEmitted fails the test if the event was emitted.
+func AssertNotEmitted(t testing.TB, rt pkg.IsRuntime, event string) {
+	t.Helper()
+
+	if records := Emitted(rt, event); len(records) > 0 {
+		t.Errorf("expected event %q not to be emitted, it was emitted %d times", event, len(records))
+	}
+}
+
+// WaitForEvent waits until the event is emitted with arguments accepted by
+// match, including events emitted before the call that are still retained,
+// and returns its record. It fails the test if it takes longer than
+// DefaultTimeout. A nil match accepts any arguments.
+func WaitForEvent(t testing.TB, rt pkg.IsRuntime, event string, match func(args ...any) bool) pkg.EventRecord {
+	t.Helper()
+
+	found := make(chan pkg.EventRecord, 1)
+
+	subscription := rt.Events().SubscribeRecords(event, func(record pkg.EventRecord) {
+		if match != nil && !match(record.Args...) {
+			return
+		}
+
+		select {
+		case found <- record:
+		default:
+		}
+	}, pkg.WithReplay(pkg.ReplayAll))
+
+	defer subscription.Unsubscribe()
+
+	select {
+	case record := <-found:
+		return record
+	case <-time.After(DefaultTimeout):
+		t.Fatalf("timed out waiting for event %q", event)
+		return pkg.EventRecord{}
+	}
+}
@@ -0,0 +1,74 @@
+package runtimetest
+
+import (
+	"sync"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+var (
+	_ pkg.IsRuntimeService    = &Fake{}
+	_ pkg.HasGracefulShutdown = &Fake{}
+)
+
+// Fake is a service that stands in for a dependency of the services under
+// test. It records how the runtime drives it, and calls the given functions,
+// if any.
+type Fake struct {
+	// ServiceName is the name of the fake.
+	ServiceName string
+
+	// OnInit and OnStop are called when the fake is initialized and shut
+	// down.
+	OnInit func(rt pkg.IsRuntime)
+	OnStop func()
+
+	mu        sync.Mutex
+	inits     int
+	shutdowns int
+}
+
+// NewFake creates a fake service with the given name.
+func NewFake(name string) *Fake {
+	return &Fake{ServiceName: name}
+}
+
+func (f *Fake) Init(rt pkg.IsRuntime) {
+	f.mu.Lock()
+	f.inits++
+	f.mu.Unlock()
+
+	if f.OnInit != nil {
+		f.OnInit(rt)
+	}
+}
+
+func (f *Fake) Name() string {
+	return f.ServiceName
+}
+
+func (f *Fake) OnShutdown() {
+	f.mu.Lock()
+	f.shutdowns++
+	f.mu.Unlock()
+
+	if f.OnStop != nil {
+		f.OnStop()
+	}
+}
+
+// Inits returns how many times the fake was initialized.
+func (f *Fake) Inits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.inits
+}
+
+// Shutdowns returns how many times the fake was shut down.
+func (f *Fake) Shutdowns() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.shutdowns
+}
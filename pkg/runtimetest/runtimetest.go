@@ -0,0 +1,155 @@
+// Package runtimetest provides a harness for testing services in a runtime,
+// without signal handling or exits. The runtime can be given a FakeClock, so
+// that its timing does not depend on the wall clock; the helpers that wait
+// for services and events only use the wall clock to bound how long they wait
+// before failing the test.
+package runtimetest
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+	"github.com/gravestench/runtime/pkg/logtest"
+)
+
+// DefaultTimeout is how long the helpers wait for services and events before
+// they fail the test. It is measured on the wall clock rather than on the
+// clock of the runtime, so that a test whose fake clock is not advanced fails
+// instead of hanging.
+const DefaultTimeout = time.Second * 5
+
+// New creates a runtime for the test. It does not handle signals, captures
+// its logs instead of writing them, and is shut down when the test ends. The
+// test fails if the shutdown takes longer than DefaultTimeout, or if
+// goroutines of the services outlive them, and the captured logs are written
+// to the test log if the test fails.
+//
+// The options are applied after those of the harness, so they can override
+// them, eg to write the logs somewhere.
+func New(t testing.TB, options ...pkg.Option) *pkg.Runtime {
+	t.Helper()
+
+	defaults := []pkg.Option{
+		pkg.WithoutSignalHandling(),
+		pkg.WithLogDestination(io.Discard),
+		pkg.WithShutdownTimeout(DefaultTimeout),
+		pkg.WithLeakDetection(),
+	}
+
+	rt := pkg.New(t.Name(), append(defaults, options...)...)
+
+	logtest.Capture(rt)
+
+	t.Cleanup(func() {
+		if !wait(rt.Shutdown(), DefaultTimeout) {
+			t.Errorf("runtime did not shut down within %s", DefaultTimeout)
+		}
+
+		for _, leak := range rt.Leaks() {
+			t.Errorf("service %q leaked %d goroutines:\n\n%s", leak.Service, len(leak.Stacks), strings.Join(leak.Stacks, "\n\n"))
+		}
+
+		if t.Failed() {
+			for _, record := range rt.Logs(pkg.LogQuery{}) {
+				t.Logf("[%s] %s: %s", record.Service, record.Level, record.Message)
+			}
+		}
+	})
+
+	return rt
+}
+
+// Add adds the services to the runtime, and waits until they are
+// initialized, failing the test if it takes longer than DefaultTimeout, or
+// if the runtime refuses to add one of them, eg because its name is taken.
+func Add(t testing.TB, rt pkg.IsRuntime, services ...pkg.IsRuntimeService) {
+	t.Helper()
+
+	for _, service := range services {
+		reported := len(unjoin(rt.Err()))
+
+		if !wait(rt.Add(service), DefaultTimeout) {
+			t.Fatalf("timed out waiting for service %q to be initialized, it is %s", service.Name(), rt.StateOf(service))
+		}
+
+		for _, err := range unjoin(rt.Err())[reported:] {
+			if errors.Is(err, pkg.ErrServiceExists) || errors.Is(err, pkg.ErrServiceNameTaken) {
+				t.Fatalf("service %q was not added: %v", service.Name(), err)
+			}
+		}
+
+		if state := rt.StateOf(service); state != pkg.ServiceStateRunning {
+			t.Fatalf("service %q was not initialized, it is %s", service.Name(), state)
+		}
+	}
+}
+
+// wait waits for the wait group on the wall clock, and reports whether it
+// was done before the timeout.
+func wait(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// unjoin returns the errors joined into the error of a runtime, oldest
+// first.
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+
+	if err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
+
+// WaitForState waits until the service is in the given state, polling it
+// every millisecond, and fails the test if it takes longer than
+// DefaultTimeout.
+func WaitForState(t testing.TB, rt pkg.IsRuntime, service pkg.IsRuntimeService, state pkg.ServiceState) {
+	t.Helper()
+
+	deadline := time.Now().Add(DefaultTimeout)
+
+	for rt.StateOf(service) != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for service %q to be %s, it is %s", service.Name(), state, rt.StateOf(service))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// AssertLogged fails the test if the named service, or services matching
+// the pattern, did not log a message containing the given text.
+func AssertLogged(t testing.TB, rt pkg.IsRuntime, service, contains string) {
+	t.Helper()
+
+	logtest.AssertLogged(t, rt, pkg.LogQuery{Service: service}, contains)
+}
+
+// AssertNotLogged fails the test if the named service, or services matching
+// the pattern, logged a message containing the given text.
+func AssertNotLogged(t testing.TB, rt pkg.IsRuntime, service, contains string) {
+	t.Helper()
+
+	logtest.AssertNotLogged(t, rt, pkg.LogQuery{Service: service}, contains)
+}
@@ -0,0 +1,108 @@
+package runtimetest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gravestench/runtime/pkg"
+	"github.com/gravestench/runtime/pkg/events"
+)
+
+type greeter struct {
+	logger *zerolog.Logger
+	db     *Fake
+}
+
+func (g *greeter) Init(rt pkg.IsRuntime) {
+	g.logger.Info().Msg("hello")
+	rt.Events().Emit("greeted", "world")
+}
+
+func (g *greeter) Name() string                      { return "greeter" }
+func (g *greeter) BindLogger(logger *zerolog.Logger) { g.logger = logger }
+func (g *greeter) Logger() *zerolog.Logger           { return g.logger }
+func (g *greeter) DependenciesResolved() bool        { return g.db != nil }
+
+func (g *greeter) ResolveDependencies(rt pkg.IsRuntime) {
+	g.db, _ = pkg.ServiceOfType[*Fake](rt)
+}
+
+func TestHarness(t *testing.T) {
+	rt := New(t)
+
+	db := NewFake("db")
+	greeter := &greeter{}
+
+	Add(t, rt, db, greeter)
+
+	if greeter.db != db {
+		t.Error("expected the greeter to depend on the fake")
+	}
+
+	WaitForState(t, rt, greeter, pkg.ServiceStateRunning)
+
+	record := WaitForEvent(t, rt, "greeted", func(args ...any) bool {
+		return len(args) == 1 && args[0] == "world"
+	})
+
+	if record.Sequence == 0 {
+		t.Error("expected a recorded event")
+	}
+
+	AssertEmitted(t, rt, events.EventServiceAdded, func(args ...any) bool {
+		return args[0] == greeter
+	})
+
+	AssertNotEmitted(t, rt, events.EventServiceReplaced)
+	AssertLogged(t, rt, "greeter", "hello")
+	AssertNotLogged(t, rt, "db", "hello")
+}
+
+func TestNew_Cleanup(t *testing.T) {
+	db := NewFake("db")
+
+	t.Run("harness", func(t *testing.T) {
+		Add(t, New(t), db)
+	})
+
+	if db.Shutdowns() != 1 {
+		t.Error("expected the runtime to be shut down when the test ends")
+	}
+}
+
+// fatalRecorder records the failure of a helper, and stops it like Fatalf.
+type fatalRecorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestAdd_Rejected(t *testing.T) {
+	rt := New(t)
+
+	Add(t, rt, NewFake("db"))
+
+	recorder := &fatalRecorder{}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		Add(recorder, rt, NewFake("db"))
+	}()
+
+	<-done
+
+	if !strings.Contains(recorder.failure, "was not added") {
+		t.Errorf("expected a duplicate name to fail the test, got %q", recorder.failure)
+	}
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCron_Next(t *testing.T) {
+	// a wednesday
+	from := time.Date(2024, 1, 3, 10, 7, 30, 0, time.UTC)
+
+	for _, test := range []struct {
+		expression string
+		next       time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 3, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 3, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2024, 1, 7, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2024, 1, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)}, // the 13th or a friday
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		cron, err := ParseCron(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next := cron.Next(from); !next.Equal(test.next) {
+			t.Errorf("%q: expected %s, got %s", test.expression, test.next, next)
+		}
+	}
+
+	// a zone offset by half an hour
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cron, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2024, 1, 3, 9, 0, 0, 0, kolkata)
+
+	if next := cron.Next(time.Date(2024, 1, 3, 8, 0, 0, 0, kolkata)); !next.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, next)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("expected %q to be invalid", expression)
+		}
+	}
+}
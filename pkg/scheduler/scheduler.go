@@ -0,0 +1,428 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gravestench/runtime/pkg"
+)
+
+// the events emitted on the runtime event bus for the runs of the jobs. The
+// arguments are the name of the job, followed by the duration of the run for
+// EventJobFinished, and the duration and error for EventJobFailed.
+const (
+	EventJobStarted  = "scheduler job started"
+	EventJobFinished = "scheduler job finished"
+	EventJobFailed   = "scheduler job failed"
+	EventJobSkipped  = "scheduler job skipped"
+)
+
+var (
+	// ErrInvalidJob is returned when scheduling a job without a name, a
+	// function, or exactly one of an interval and a cron expression.
+	ErrInvalidJob = errors.New("invalid job")
+
+	// ErrJobExists is returned when scheduling a job with the name of a job
+	// that is already scheduled.
+	ErrJobExists = errors.New("job already scheduled")
+
+	// ErrJobTimeout is the error of a run that exceeded the timeout of its
+	// job.
+	ErrJobTimeout = errors.New("job timed out")
+)
+
+var (
+	_ pkg.IsRuntimeService    = &Scheduler{}
+	_ pkg.HasLogger           = &Scheduler{}
+	_ pkg.HasClock            = &Scheduler{}
+	_ pkg.HasTaskGroup        = &Scheduler{}
+	_ pkg.HasGracefulShutdown = &Scheduler{}
+)
+
+// Job describes a function run on a schedule.
+type Job struct {
+	// Name identifies the job in events, logs and stats.
+	Name string
+
+	// Every is the interval between the runs of the job. The first run
+	// happens one interval after the job is started.
+	Every time.Duration
+
+	// Cron is a cron expression, as understood by ParseCron, for when the
+	// job runs. Mutually exclusive with Every.
+	Cron string
+
+	// Jitter is the upper bound of a random delay added to each run, so
+	// that jobs of many processes do not run all at once.
+	Jitter time.Duration
+
+	// Timeout is how long a run may take before its context is cancelled.
+	// Zero is not limited.
+	Timeout time.Duration
+
+	// AllowOverlap lets a run start while the previous one is still going.
+	// By default, a run that is due while the previous one is still going
+	// is skipped.
+	AllowOverlap bool
+
+	// Run is the function of the job. Its context is cancelled when the
+	// run times out, and when the scheduler is shut down.
+	Run func(ctx context.Context) error
+}
+
+// JobStats holds the metrics of the runs of a job.
+type JobStats struct {
+	// Runs is the number of runs that were started.
+	Runs uint64
+
+	// Failures is the number of runs that returned an error, including
+	// those that timed out.
+	Failures uint64
+
+	// Timeouts is the number of runs that exceeded the timeout of the job.
+	Timeouts uint64
+
+	// Skipped is the number of runs that were skipped because the previous
+	// run was still going.
+	Skipped uint64
+
+	// Running is the number of runs currently going.
+	Running int
+
+	// LastRun is when the last run started.
+	LastRun time.Time
+
+	// LastDuration is how long the last finished run took.
+	LastDuration time.Duration
+
+	// LastError is the error of the last finished run, if any.
+	LastError error
+}
+
+// Scheduler is a runtime service that runs jobs at intervals or on cron
+// schedules. The runs are cancelled when the scheduler is shut down.
+type Scheduler struct {
+	rt     pkg.IsRuntime
+	logger *zerolog.Logger
+	clock  pkg.Clock
+	tasks  *pkg.TaskGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+}
+
+type job struct {
+	Job
+	cron *Cron
+	stop chan struct{}
+
+	stats JobStats
+}
+
+// New creates a scheduler. Add it to a runtime to start running the jobs
+// scheduled with it.
+func New() *Scheduler {
+	return &Scheduler{
+		clock: pkg.SystemClock,
+		jobs:  make(map[string]*job),
+	}
+}
+
+// Init starts the jobs scheduled before the scheduler was added to the
+// runtime.
+func (s *Scheduler) Init(rt pkg.IsRuntime) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rt = rt
+	s.started = true
+
+	for _, j := range s.jobs {
+		s.start(j)
+	}
+}
+
+// Name returns the name of the scheduler service.
+func (s *Scheduler) Name() string {
+	return "Scheduler"
+}
+
+// BindLogger sets the logger of the scheduler.
+func (s *Scheduler) BindLogger(logger *zerolog.Logger) {
+	s.logger = logger
+}
+
+// Logger yields the logger of the scheduler.
+func (s *Scheduler) Logger() *zerolog.Logger {
+	return s.logger
+}
+
+// BindClock sets the clock on which the jobs are scheduled.
+func (s *Scheduler) BindClock(clock pkg.Clock) {
+	s.clock = clock
+}
+
+// BindTaskGroup sets the task group in which the jobs run.
+func (s *Scheduler) BindTaskGroup(tasks *pkg.TaskGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks = tasks
+}
+
+// OnShutdown stops scheduling the jobs. The runs still going are cancelled
+// by the runtime along with the other tasks of the scheduler.
+func (s *Scheduler) OnShutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = false
+
+	for _, j := range s.jobs {
+		close(j.stop)
+		j.stop = make(chan struct{})
+	}
+}
+
+// Schedule adds a job to the scheduler. Jobs can be scheduled before the
+// scheduler is added to a runtime, and start once it is initialized.
+func (s *Scheduler) Schedule(spec Job) error {
+	j := &job{Job: spec, stop: make(chan struct{})}
+
+	switch {
+	case spec.Name == "":
+		return fmt.Errorf("%w: a name is required", ErrInvalidJob)
+	case spec.Run == nil:
+		return fmt.Errorf("%w %q: a function is required", ErrInvalidJob, spec.Name)
+	case (spec.Every > 0) == (spec.Cron != ""):
+		return fmt.Errorf("%w %q: exactly one of an interval and a cron expression is required", ErrInvalidJob, spec.Name)
+	}
+
+	if spec.Cron != "" {
+		cron, err := ParseCron(spec.Cron)
+		if err != nil {
+			return fmt.Errorf("%w %q: %v", ErrInvalidJob, spec.Name, err)
+		}
+
+		j.cron = cron
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.jobs[spec.Name]; found {
+		return fmt.Errorf("%w: %q", ErrJobExists, spec.Name)
+	}
+
+	s.jobs[spec.Name] = j
+
+	if s.started {
+		s.start(j)
+	}
+
+	return nil
+}
+
+// Unschedule removes a job from the scheduler. A run still going is not
+// cancelled. It returns false if no job has the given name.
+func (s *Scheduler) Unschedule(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, found := s.jobs[name]
+	if !found {
+		return false
+	}
+
+	close(j.stop)
+	delete(s.jobs, name)
+
+	return true
+}
+
+// Jobs returns the names of the scheduled jobs, sorted.
+func (s *Scheduler) Jobs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Stats returns the metrics of the named job, and false if no job has the
+// given name.
+func (s *Scheduler) Stats(name string) (JobStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, found := s.jobs[name]
+	if !found {
+		return JobStats{}, false
+	}
+
+	return j.stats, true
+}
+
+// start runs the loop of a job as a task of the scheduler. The lock must be
+// held.
+func (s *Scheduler) start(j *job) {
+	stop := j.stop
+
+	s.tasks.Go(func(ctx context.Context) error {
+		s.loop(ctx, j, stop)
+		return nil
+	})
+}
+
+// loop waits for each activation of a job, and triggers its run, until the
+// job is unscheduled or the scheduler is shut down. When activations were
+// missed, the job runs once, and then resumes at its next activation.
+func (s *Scheduler) loop(ctx context.Context, j *job, stop chan struct{}) {
+	due := s.clock.Now()
+
+	for {
+		due = j.next(due)
+
+		// the activations missed while the clock jumped ahead, or while the
+		// process was suspended, are skipped rather than run in a burst
+		now := s.clock.Now()
+
+		for !due.IsZero() && !due.After(now) {
+			due = j.next(due)
+		}
+
+		if due.IsZero() {
+			s.logger.Warn().Msgf("job %q has no next run", j.Name)
+			return
+		}
+
+		delay := due.Sub(s.clock.Now())
+		if j.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(j.Jitter)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-s.clock.After(delay):
+		}
+
+		s.trigger(j)
+	}
+}
+
+// next returns the activation of the job following the given one.
+func (j *job) next(after time.Time) time.Time {
+	if j.cron != nil {
+		return j.cron.Next(after)
+	}
+
+	return after.Add(j.Every)
+}
+
+// trigger starts a run of the job, unless the previous one is still going
+// and the job does not allow overlapping runs.
+func (s *Scheduler) trigger(j *job) {
+	s.mu.Lock()
+
+	if j.stats.Running > 0 && !j.AllowOverlap {
+		j.stats.Skipped++
+		s.mu.Unlock()
+
+		s.logger.Warn().Msgf("skipping job %q, the previous run is still going", j.Name)
+		s.rt.Events().Emit(EventJobSkipped, j.Name)
+
+		return
+	}
+
+	j.stats.Runs++
+	j.stats.Running++
+	j.stats.LastRun = s.clock.Now()
+	s.mu.Unlock()
+
+	s.tasks.Go(func(ctx context.Context) error {
+		s.run(ctx, j)
+		return nil
+	})
+}
+
+// run runs the job once, with its timeout, and records the outcome.
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if j.Timeout > 0 {
+		timeout := s.clock.After(j.Timeout)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-timeout:
+				cancel(ErrJobTimeout)
+			}
+		}()
+	}
+
+	s.logger.Debug().Msgf("running job %q", j.Name)
+	s.rt.Events().Emit(EventJobStarted, j.Name)
+
+	start := s.clock.Now()
+	err := s.call(ctx, j)
+	elapsed := s.clock.Now().Sub(start)
+
+	timedOut := errors.Is(context.Cause(ctx), ErrJobTimeout)
+	if timedOut && (err == nil || errors.Is(err, context.Canceled)) {
+		err = ErrJobTimeout
+	}
+
+	s.mu.Lock()
+	j.stats.Running--
+	j.stats.LastDuration = elapsed
+	j.stats.LastError = err
+
+	if err != nil {
+		j.stats.Failures++
+	}
+
+	if timedOut {
+		j.stats.Timeouts++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.Error().Err(err).Msgf("job %q failed after %s", j.Name, elapsed)
+		s.rt.Events().Emit(EventJobFailed, j.Name, elapsed, err)
+
+		return
+	}
+
+	s.logger.Debug().Msgf("job %q finished in %s", j.Name, elapsed)
+	s.rt.Events().Emit(EventJobFinished, j.Name, elapsed)
+}
+
+// call calls the function of the job, recovering from a panic.
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panic: %v", recovered)
+		}
+	}()
+
+	return j.Run(ctx)
+}
@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gravestench/runtime/pkg"
+	"github.com/gravestench/runtime/pkg/runtimetest"
+)
+
+func newScheduler(t *testing.T, now time.Time) (*pkg.Runtime, *Scheduler, *runtimetest.FakeClock) {
+	clock := runtimetest.NewFakeClock(now)
+	rt := runtimetest.New(t, pkg.WithClock(clock))
+	s := New()
+
+	runtimetest.Add(t, rt, s)
+
+	return rt, s, clock
+}
+
+func TestScheduler_Every(t *testing.T) {
+	rt, s, clock := newScheduler(t, time.Now())
+
+	runs := make(chan struct{})
+
+	err := s.Schedule(Job{Name: "poll", Every: time.Minute, Run: func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-runs
+	}
+
+	runtimetest.WaitForEvent(t, rt, EventJobFinished, func(args ...any) bool {
+		return args[0] == "poll"
+	})
+
+	if stats, _ := s.Stats("poll"); stats.Runs != 3 {
+		t.Errorf("expected 3 runs, got %d", stats.Runs)
+	}
+}
+
+func TestScheduler_MissedRuns(t *testing.T) {
+	start := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+	rt, s, clock := newScheduler(t, start)
+
+	ran := make(chan time.Time, 10)
+
+	err := s.Schedule(Job{Name: "poll", Every: time.Minute, Run: func(ctx context.Context) error {
+		ran <- clock.Now()
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Minute)
+	<-ran
+
+	// the loop waits for the next activation rather than catching up
+	clock.BlockUntil(1)
+	runtimetest.AssertNotEmitted(t, rt, EventJobSkipped)
+
+	if stats, _ := s.Stats("poll"); stats.Runs != 1 {
+		t.Errorf("expected the missed runs to be skipped, got %d runs", stats.Runs)
+	}
+
+	clock.Advance(time.Minute)
+
+	if at := <-ran; !at.Equal(start.Add(6 * time.Minute)) {
+		t.Errorf("expected the next run one minute later, got %s", at)
+	}
+}
+
+func TestScheduler_Cron(t *testing.T) {
+	rt, s, clock := newScheduler(t, time.Date(2024, 1, 3, 10, 7, 30, 0, time.UTC))
+
+	ran := make(chan time.Time, 1)
+
+	// jobs scheduled after the scheduler is running start right away
+	err := s.Schedule(Job{Name: "report", Cron: "*/15 * * * *", Run: func(ctx context.Context) error {
+		ran <- clock.Now()
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(7 * time.Minute)
+
+	runtimetest.AssertNotEmitted(t, rt, EventJobStarted)
+
+	clock.Advance(30 * time.Second)
+
+	if at := <-ran; !at.Equal(time.Date(2024, 1, 3, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("unexpected run at %s", at)
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	rt, s, clock := newScheduler(t, time.Now())
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	err := s.Schedule(Job{Name: "slow", Every: time.Minute, Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-started
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	runtimetest.WaitForEvent(t, rt, EventJobSkipped, nil)
+	close(release)
+
+	if stats, _ := s.Stats("slow"); stats.Runs != 1 || stats.Skipped != 1 {
+		t.Errorf("expected 1 run and 1 skipped run, got %+v", stats)
+	}
+}
+
+func TestScheduler_Timeout(t *testing.T) {
+	rt, s, clock := newScheduler(t, time.Now())
+
+	err := s.Schedule(Job{Name: "stuck", Every: time.Minute, Timeout: time.Second, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	// the loop waits for the next run, and the run for its timeout
+	clock.BlockUntil(2)
+	clock.Advance(time.Second)
+
+	runtimetest.WaitForEvent(t, rt, EventJobFailed, func(args ...any) bool {
+		err, _ := args[2].(error)
+		return errors.Is(err, ErrJobTimeout)
+	})
+
+	if stats, _ := s.Stats("stuck"); stats.Failures != 1 || stats.Timeouts != 1 {
+		t.Errorf("expected 1 timed out run, got %+v", stats)
+	}
+}
+
+func TestScheduler_Shutdown(t *testing.T) {
+	rt, s, clock := newScheduler(t, time.Now())
+
+	started, stopped := make(chan struct{}), make(chan struct{})
+
+	err := s.Schedule(Job{Name: "forever", Every: time.Minute, Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-started
+
+	rt.Shutdown().Wait()
+
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the run to be cancelled on shutdown")
+	}
+}
+
+func TestScheduler_Schedule(t *testing.T) {
+	s := New()
+	run := func(context.Context) error { return nil }
+
+	for _, job := range []Job{
+		{Every: time.Second, Run: run},
+		{Name: "no run", Every: time.Second},
+		{Name: "no schedule", Run: run},
+		{Name: "both", Every: time.Second, Cron: "@daily", Run: run},
+		{Name: "bad cron", Cron: "every day", Run: run},
+	} {
+		if err := s.Schedule(job); !errors.Is(err, ErrInvalidJob) {
+			t.Errorf("expected job %q to be invalid, got %v", job.Name, err)
+		}
+	}
+
+	if err := s.Schedule(Job{Name: "job", Every: time.Second, Run: run}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Schedule(Job{Name: "job", Every: time.Second, Run: run}); !errors.Is(err, ErrJobExists) {
+		t.Errorf("expected a duplicate job to be rejected, got %v", err)
+	}
+
+	if !s.Unschedule("job") || len(s.Jobs()) != 0 {
+		t.Error("expected the job to be unscheduled")
+	}
+}